@@ -0,0 +1,57 @@
+package common
+
+import (
+	"encoding"
+	"sync"
+)
+
+// WirePayload is implemented by payloads that can be sent over the wire.
+// TypeName names the payload type in a PayloadFactory, and the bytes from
+// MarshalBinary are what the registered constructor receives on the other
+// end.
+type WirePayload interface {
+	PayloadContainer
+	encoding.BinaryMarshaler
+	TypeName() string
+}
+
+// PayloadFactory is a registry of payload constructors by type name, used to
+// rebuild payloads from their wire encoding. It is safe for concurrent use;
+// the zero value is an empty registry.
+type PayloadFactory struct {
+	mu   sync.RWMutex
+	ctor map[string]func(data []byte) PayloadContainer
+}
+
+// Register makes ctor the constructor for payloads of type typeName. ctor
+// should return nil if data is not a valid encoding. Returns false, leaving
+// the registry untouched, if typeName is empty or already registered, or if
+// ctor is nil.
+func (f *PayloadFactory) Register(typeName string, ctor func(data []byte) PayloadContainer) bool {
+	if typeName == "" || ctor == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.ctor[typeName]; ok {
+		return false
+	}
+	if f.ctor == nil {
+		f.ctor = make(map[string]func(data []byte) PayloadContainer)
+	}
+	f.ctor[typeName] = ctor
+	return true
+}
+
+// New builds a payload of type typeName from data. Returns false if the
+// type isn't registered or its constructor rejects data.
+func (f *PayloadFactory) New(typeName string, data []byte) (PayloadContainer, bool) {
+	f.mu.RLock()
+	ctor, ok := f.ctor[typeName]
+	f.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	p := ctor(data)
+	return p, p != nil
+}
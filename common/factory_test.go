@@ -0,0 +1,67 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// namedVec is a minimal WirePayload for tests.
+type namedVec struct {
+	V    []float64 `json:"v"`
+	Name string    `json:"name"`
+}
+
+func (p *namedVec) Vec() []float64                 { return p.V }
+func (p *namedVec) Expired() bool                  { return false }
+func (p *namedVec) TypeName() string               { return "namedVec" }
+func (p *namedVec) MarshalBinary() ([]byte, error) { return json.Marshal(p) }
+
+func newNamedVec(data []byte) PayloadContainer {
+	var p namedVec
+	if json.Unmarshal(data, &p) != nil {
+		return nil
+	}
+	return &p
+}
+
+func TestPayloadFactory(t *testing.T) {
+	var f PayloadFactory
+	if !f.Register("namedVec", newNamedVec) {
+		t.Fatal("failed to register")
+	}
+
+	var orig WirePayload = &namedVec{V: []float64{1, 2}, Name: "a"}
+	data, err := orig.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := f.New(orig.TypeName(), data)
+	if !ok {
+		t.Fatal("failed to rebuild payload")
+	}
+	if !reflect.DeepEqual(p, orig) {
+		t.Errorf("got %+v, want %+v", p, orig)
+	}
+
+	if _, ok := f.New("namedVec", []byte("{")); ok {
+		t.Error("built payload from invalid data")
+	}
+	if _, ok := f.New("other", data); ok {
+		t.Error("built payload of unregistered type")
+	}
+}
+
+func TestPayloadFactoryRegisterInvalid(t *testing.T) {
+	var f PayloadFactory
+	f.Register("namedVec", newNamedVec)
+	if f.Register("namedVec", newNamedVec) {
+		t.Error("registered duplicate type")
+	}
+	if f.Register("", newNamedVec) {
+		t.Error("registered empty type name")
+	}
+	if f.Register("nil", nil) {
+		t.Error("registered nil constructor")
+	}
+}
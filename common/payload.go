@@ -0,0 +1,40 @@
+// Package common holds types shared between the clustering, search and
+// network packages.
+package common
+
+import "time"
+
+// PayloadContainer is the unit of data stored in and moved between
+// centroids. Vec is the vector used for clustering and search; Expired
+// reports whether the payload should no longer be considered.
+type PayloadContainer interface {
+	Vec() []float64
+	Expired() bool
+}
+
+// Timestamped is implemented by payloads that know when they were created.
+// It is optional: features that order or filter payloads by age use it when
+// a payload provides it.
+type Timestamped interface {
+	Created() time.Time
+}
+
+// Unwrapper is implemented by payloads that wrap another payload, such as
+// those centroids store in place of payloads they normalize. Optional
+// interfaces like Timestamped and WirePayload belong to the wrapped
+// payload, so check them on the result of Unwrap.
+type Unwrapper interface {
+	Unwrap() PayloadContainer
+}
+
+// Unwrap returns the innermost payload wrapped by p, following Unwrapper,
+// or p itself if it wraps nothing.
+func Unwrap(p PayloadContainer) PayloadContainer {
+	for {
+		u, ok := p.(Unwrapper)
+		if !ok {
+			return p
+		}
+		p = u.Unwrap()
+	}
+}
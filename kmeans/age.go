@@ -0,0 +1,59 @@
+package kmeans
+
+import (
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+)
+
+// payloadAge returns how long ago p was created according to now, or false
+// if p does not implement common.Timestamped.
+func payloadAge(p payloadContainer, now time.Time) (time.Duration, bool) {
+	ts, ok := common.Unwrap(p).(common.Timestamped)
+	if !ok {
+		return 0, false
+	}
+	return now.Sub(ts.Created()), true
+}
+
+// AgeDistribution returns a histogram of the ages of the non-expired
+// payloads, as of the centroid clock. buckets are ascending upper bounds:
+// count i holds payloads younger than buckets[i] (and at least buckets[i-1]
+// old), and the extra last count holds payloads at least as old as the last
+// bound. Payloads that don't implement common.Timestamped are not counted.
+// Returns nil if buckets is not strictly ascending.
+func (c *Centroid) AgeDistribution(buckets []time.Duration) []int {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return nil
+		}
+	}
+	now := c.clock()
+	counts := make([]int, len(buckets)+1)
+	for _, p := range c.DataPoints {
+		if p.Expired() {
+			continue
+		}
+		age, ok := payloadAge(p, now)
+		if !ok {
+			continue
+		}
+		i := 0
+		for i < len(buckets) && age >= buckets[i] {
+			i++
+		}
+		counts[i]++
+	}
+	return counts
+}
+
+// ExpireOlderThan removes every payload created more than age before now,
+// regardless of whether it has expired by its own measure, and returns how
+// many were removed. This enforces retention policies on top of payload
+// TTLs. Payloads that don't implement common.Timestamped are kept.
+func (c *Centroid) ExpireOlderThan(age time.Duration, now time.Time) int {
+	return c.removeIf(func(p payloadContainer) bool {
+		a, ok := payloadAge(p, now)
+		return ok && a > age
+	})
+}
@@ -0,0 +1,71 @@
+package kmeans
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// newAgedTestCentroid returns a centroid whose clock is fixed at now,
+// holding timed payloads created the given durations before now.
+func newAgedTestCentroid(t *testing.T, now time.Time, ages ...time.Duration) *Centroid {
+	t.Helper()
+	c, ok := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		Clock:         func() time.Time { return now },
+	})
+	if !ok {
+		t.Fatal("failed to create centroid")
+	}
+	for i, age := range ages {
+		p := &timedPayload{testPayload{vec: []float64{float64(i)}}, now.Add(-age)}
+		if !c.AddPayload(p) {
+			t.Fatalf("failed to add payload aged %v", age)
+		}
+	}
+	return c
+}
+
+func TestCentroidAgeDistribution(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newAgedTestCentroid(t, now,
+		30*time.Second, time.Minute, 5*time.Minute, 2*time.Hour, 3*time.Hour, 48*time.Hour)
+	c.AddPayload(&testPayload{vec: []float64{9}}) // No timestamp.
+
+	got := c.AgeDistribution([]time.Duration{time.Minute, time.Hour, 24 * time.Hour})
+	if want := []int{1, 2, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCentroidAgeDistributionInvalid(t *testing.T) {
+	c := newAgedTestCentroid(t, time.Now(), time.Minute)
+	if got := c.AgeDistribution([]time.Duration{time.Hour, time.Minute}); got != nil {
+		t.Errorf("descending buckets: got %v, want nil", got)
+	}
+	if got := c.AgeDistribution(nil); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("no buckets: got %v, want [1]", got)
+	}
+}
+
+func TestCentroidExpireOlderThan(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newAgedTestCentroid(t, now, time.Minute, 3*time.Hour, 10*time.Second, 25*time.Hour)
+	c.AddPayload(&testPayload{vec: []float64{9}}) // No timestamp.
+
+	if n := c.ExpireOlderThan(time.Hour, now); n != 2 {
+		t.Errorf("removed %d, want 2", n)
+	}
+	if want := [][]float64{{0}, {2}, {9}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("remaining %v, want %v", dpVecs(c), want)
+	}
+	if n := c.ExpireOlderThan(time.Hour, now); n != 0 {
+		t.Errorf("second pass removed %d, want 0", n)
+	}
+}
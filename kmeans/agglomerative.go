@@ -0,0 +1,178 @@
+package kmeans
+
+import (
+	"math"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// Linkage criteria accepted by Agglomerative.
+const (
+	// LinkageSingle uses the distance between the closest members.
+	LinkageSingle = "single"
+	// LinkageComplete uses the distance between the furthest members.
+	LinkageComplete = "complete"
+	// LinkageAverage uses the mean distance over all member pairs.
+	LinkageAverage = "average"
+)
+
+// linkageUpdate computes the distance between a freshly merged cluster (a+b)
+// and some other cluster x, given the distances d(a,x), d(b,x) and the sizes
+// of a and b (Lance-Williams form).
+type linkageUpdate func(dax, dbx float64, na, nb int) float64
+
+var linkageUpdates = map[string]linkageUpdate{
+	LinkageSingle: func(dax, dbx float64, _, _ int) float64 {
+		return math.Min(dax, dbx)
+	},
+	LinkageComplete: func(dax, dbx float64, _, _ int) float64 {
+		return math.Max(dax, dbx)
+	},
+	LinkageAverage: func(dax, dbx float64, na, nb int) float64 {
+		return (float64(na)*dax + float64(nb)*dbx) / float64(na+nb)
+	},
+}
+
+// Merge is one step of agglomerative clustering: the clusters identified by
+// A and B were joined at linkage distance Dist. Clusters are identified by
+// the lowest index among their members, so A < B and the merged cluster
+// keeps the identifier A.
+type Merge struct {
+	A, B int
+	Dist float64
+}
+
+// Dendrogram is the full merge history of agglomerative clustering over n
+// vectors, in merge order. It can be cut at any number of clusters.
+type Dendrogram struct {
+	n      int
+	Merges []Merge
+}
+
+// CutAt returns the labels obtained by stopping the merge history when
+// nClusters clusters remain, in the same form as Agglomerative. Returns nil
+// if nClusters is out of range for the tree.
+func (d *Dendrogram) CutAt(nClusters int) []int {
+	if nClusters < 1 || nClusters > d.n || d.n-nClusters > len(d.Merges) {
+		return nil
+	}
+	owner := make([]int, d.n)
+	for i := range owner {
+		owner[i] = i
+	}
+	for _, m := range d.Merges[:d.n-nClusters] {
+		for i := range owner {
+			if owner[i] == m.B {
+				owner[i] = m.A
+			}
+		}
+	}
+	return relabel(owner)
+}
+
+// Agglomerative clusters vecs bottom-up: every vector starts as its own
+// cluster, and the two closest clusters (according to linkage, which is one
+// of LinkageSingle, LinkageComplete or LinkageAverage) are merged until k
+// clusters remain. The pairwise distance matrix is computed once and updated
+// in place on every merge.
+//
+// The returned slice holds one label in [0, k) per vector, numbered in order
+// of first appearance. Ties are broken by lowest index, so the result is
+// deterministic. Returns nil if k is out of range, the linkage is unknown,
+// metric is nil, or metric fails for any pair of vectors.
+func Agglomerative(vecs [][]float64, k int, linkage string, metric mathutils.Metric) []int {
+	if k < 1 || k > len(vecs) {
+		return nil
+	}
+	merges, ok := agglomerate(vecs, k, linkage, metric)
+	if !ok {
+		return nil
+	}
+	return (&Dendrogram{n: len(vecs), Merges: merges}).CutAt(k)
+}
+
+// AgglomerativeTree is like Agglomerative but merges all the way down to a
+// single cluster and returns the whole merge history, so the tree can be cut
+// at any level with CutAt or visualised. Returns nil on the same conditions
+// as Agglomerative, or if vecs is empty.
+func AgglomerativeTree(vecs [][]float64, linkage string, metric mathutils.Metric) *Dendrogram {
+	if len(vecs) == 0 {
+		return nil
+	}
+	merges, ok := agglomerate(vecs, 1, linkage, metric)
+	if !ok {
+		return nil
+	}
+	return &Dendrogram{n: len(vecs), Merges: merges}
+}
+
+// agglomerate runs the merge loop until k clusters remain and returns the
+// merges performed. Returns false if the linkage is unknown, metric is nil
+// or metric fails.
+func agglomerate(vecs [][]float64, k int, linkage string, metric mathutils.Metric) ([]Merge, bool) {
+	update, ok := linkageUpdates[linkage]
+	if !ok || metric == nil {
+		return nil, false
+	}
+	dist, err := mathutils.DistanceMatrix(vecs, metric)
+	if err != nil {
+		return nil, false
+	}
+	return mergeClosest(dist, k, update), true
+}
+
+// mergeClosest runs the merge loop of agglomerate over the distance matrix
+// dist, which it modifies, until k clusters remain.
+func mergeClosest(dist [][]float64, k int, update linkageUpdate) []Merge {
+	n := len(dist)
+
+	// Clusters are identified by the index of their first member.
+	active := make([]bool, n)
+	sizes := make([]int, n)
+	for i := range active {
+		active[i], sizes[i] = true, 1
+	}
+
+	merges := make([]Merge, 0, n-k)
+	for remaining := n; remaining > k; remaining-- {
+		a, b := -1, -1
+		for i := 0; i < n; i++ {
+			if !active[i] {
+				continue
+			}
+			for j := i + 1; j < n; j++ {
+				if active[j] && (a == -1 || dist[i][j] < dist[a][b]) {
+					a, b = i, j
+				}
+			}
+		}
+		merges = append(merges, Merge{A: a, B: b, Dist: dist[a][b]})
+
+		for x := 0; x < n; x++ {
+			if !active[x] || x == a || x == b {
+				continue
+			}
+			d := update(dist[a][x], dist[b][x], sizes[a], sizes[b])
+			dist[a][x], dist[x][a] = d, d
+		}
+		active[b] = false
+		sizes[a] += sizes[b]
+	}
+	return merges
+}
+
+// relabel maps arbitrary cluster identifiers to labels in [0, n), numbered
+// in order of first appearance.
+func relabel(owner []int) []int {
+	ids := make(map[int]int)
+	labels := make([]int, len(owner))
+	for i, o := range owner {
+		label, ok := ids[o]
+		if !ok {
+			label = len(ids)
+			ids[o] = label
+		}
+		labels[i] = label
+	}
+	return labels
+}
@@ -0,0 +1,96 @@
+package kmeans
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// agglomerativeData is a 1D dataset where the linkages disagree:
+// merges are (13,14.5)@1.5, (0,3)@3, then single linkage chains 6 and 9
+// onto {0,3}, while complete linkage pairs (6,9) and attaches them to
+// {13,14.5} at 8.5 (< 9 to {0,3}). Average linkage scores {0,3}-{6,9} at
+// 6 and {6,9}-{13,14.5} at 6.25, so it sides with single.
+var agglomerativeData = [][]float64{{0}, {3}, {6}, {9}, {13}, {14.5}}
+
+func TestAgglomerativeLinkages(t *testing.T) {
+	tests := []struct {
+		linkage string
+		want    []int
+	}{
+		{LinkageSingle, []int{0, 0, 0, 0, 1, 1}},
+		{LinkageComplete, []int{0, 0, 1, 1, 1, 1}},
+		{LinkageAverage, []int{0, 0, 0, 0, 1, 1}},
+	}
+	for _, test := range tests {
+		got := Agglomerative(agglomerativeData, 2, test.linkage, mathutils.EuclideanDistance)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.linkage, got, test.want)
+		}
+	}
+}
+
+func TestAgglomerativeKBounds(t *testing.T) {
+	got := Agglomerative(agglomerativeData, len(agglomerativeData), LinkageSingle, mathutils.EuclideanDistance)
+	want := []int{0, 1, 2, 3, 4, 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("k=n: got %v, want %v", got, want)
+	}
+
+	got = Agglomerative(agglomerativeData, 1, LinkageComplete, mathutils.EuclideanDistance)
+	want = []int{0, 0, 0, 0, 0, 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("k=1: got %v, want %v", got, want)
+	}
+}
+
+func TestAgglomerativeInvalid(t *testing.T) {
+	euc := mathutils.EuclideanDistance
+	if got := Agglomerative(agglomerativeData, 0, LinkageSingle, euc); got != nil {
+		t.Errorf("k=0: got %v, want nil", got)
+	}
+	if got := Agglomerative(agglomerativeData, 7, LinkageSingle, euc); got != nil {
+		t.Errorf("k>n: got %v, want nil", got)
+	}
+	if got := Agglomerative(agglomerativeData, 2, "ward", euc); got != nil {
+		t.Errorf("unknown linkage: got %v, want nil", got)
+	}
+	if got := Agglomerative([][]float64{{1}, {1, 2}}, 1, LinkageSingle, euc); got != nil {
+		t.Errorf("dim mismatch: got %v, want nil", got)
+	}
+}
+
+func TestAgglomerativeTreeCompleteMonotonic(t *testing.T) {
+	vecs := [][]float64{
+		{0, 0}, {1, 0.5}, {4, 4}, {4.5, 3}, {9, 1}, {8, 0}, {2, 7}, {3, 8}, {0.5, 1},
+	}
+	tree := AgglomerativeTree(vecs, LinkageComplete, mathutils.EuclideanDistance)
+	if tree == nil {
+		t.Fatal("unexpected nil tree")
+	}
+	if len(tree.Merges) != len(vecs)-1 {
+		t.Fatalf("got %d merges, want %d", len(tree.Merges), len(vecs)-1)
+	}
+	for i := 1; i < len(tree.Merges); i++ {
+		if tree.Merges[i].Dist < tree.Merges[i-1].Dist {
+			t.Errorf("merge %d distance %v decreased from %v",
+				i, tree.Merges[i].Dist, tree.Merges[i-1].Dist)
+		}
+	}
+}
+
+func TestDendrogramCutAt(t *testing.T) {
+	for _, linkage := range []string{LinkageSingle, LinkageComplete, LinkageAverage} {
+		tree := AgglomerativeTree(agglomerativeData, linkage, mathutils.EuclideanDistance)
+		for k := 1; k <= len(agglomerativeData); k++ {
+			want := Agglomerative(agglomerativeData, k, linkage, mathutils.EuclideanDistance)
+			if got := tree.CutAt(k); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s k=%d: got %v, want %v", linkage, k, got, want)
+			}
+		}
+		if got := tree.CutAt(0); got != nil {
+			t.Errorf("%s: CutAt(0) got %v, want nil", linkage, got)
+		}
+	}
+}
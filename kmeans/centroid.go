@@ -0,0 +1,1172 @@
+// Package kmeans implements centroids that hold payloads, k-means style
+// models built from them, and related clustering utilities.
+package kmeans
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// payloadContainer is the payload type stored by centroids.
+type payloadContainer = common.PayloadContainer
+
+// knnSearchFunc finds the indexes of the k vectors from vecs that best match
+// target, best first. Vectors that match equally well are expected to keep
+// their generation order. The funcs in the searchutils package satisfy it.
+type knnSearchFunc func(target []float64, vecs func() ([]float64, bool), k int) []int
+
+// PayloadReceiver is anything a Centroid can hand payloads to, such as
+// another Centroid or a remote node.
+type PayloadReceiver interface {
+	Vec() []float64
+	AddPayload(p payloadContainer) bool
+}
+
+// HealthReporter is optionally implemented by PayloadReceivers that know
+// when they can't take payloads, such as remote nodes behind a circuit
+// breaker. DistributePayload skips receivers reporting unhealthy.
+type HealthReporter interface {
+	Healthy() bool
+}
+
+// Centroid is a cluster centre along with the payloads assigned to it.
+type Centroid struct {
+	id  string
+	vec []float64
+	// DataPoints are the payloads currently held by the centroid.
+	DataPoints []payloadContainer
+
+	knnSearchFunc knnSearchFunc
+	kfnSearchFunc knnSearchFunc
+	drainTieBreak func(a, b payloadContainer) bool
+	metric        mathutils.Metric
+	growthHint    int
+	clock         func() time.Time
+	zeroFallback  bool
+	autoNormalize bool
+	compactRatio  float64
+	onReject      func(p payloadContainer, reason string)
+	reservoir     reservoir
+	updater       Updater
+	factory       *common.PayloadFactory
+
+	// sse tracks SSE incrementally; see TrackedSSE. It is recomputed on
+	// the next read once sseStale is set.
+	sse      float64
+	sseStale bool
+	// sum tracks the vectors of DataPoints so that MoveVector can take the
+	// mean without a pass over them; see moveToSum. Removals set sumStale,
+	// and the sum is rebuilt by the next full MoveVector.
+	sum      mathutils.RunningSum
+	sumStale bool
+}
+
+// NewCentroidArgs is the argument set for NewCentroid.
+type NewCentroidArgs struct {
+	// ID identifies the centroid across nodes. Defaults to a hash of
+	// InitVec, so every node creating a centroid from the same vector
+	// agrees on its ID; set it explicitly where initial vectors may repeat.
+	ID string
+	// InitVec is the initial centroid vector. It is copied, and fixes the
+	// dimension of payloads the centroid accepts.
+	InitVec []float64
+	// InitCap is the initial capacity of DataPoints.
+	InitCap int
+	// GrowthHint, if positive, is how many slots DataPoints grows by when
+	// AddPayload runs out of capacity, instead of append's default growth.
+	// Useful for large ingests of predictable size; see also Reserve.
+	GrowthHint int
+	// KNNSearchFunc ranks best matches, e.g. searchutils.KNNCos. If nil,
+	// it is derived from Metric (see searchutils.KNNByMetric).
+	KNNSearchFunc knnSearchFunc
+	// KFNSearchFunc ranks worst matches, e.g. searchutils.KFNCos, and so
+	// decides what DrainOrdered drains. If nil, it is derived from Metric
+	// (see searchutils.KFNByMetric), so a centroid configured only with a
+	// cosine Metric drains its least similar payloads and one configured
+	// with a Euclidean Metric its most distant ones.
+	KFNSearchFunc knnSearchFunc
+	// DrainTieBreak optionally orders payloads that DrainOrdered finds
+	// equally bad: a drains before b if it returns true. Without it, ties
+	// drain in DataPoints order. See OldestFirst.
+	DrainTieBreak func(a, b payloadContainer) bool
+	// Metric is the distance used where the centroid reports distances
+	// itself rather than through the search funcs, e.g. the scores of
+	// DrainOrderedWithScores. It must agree with the search funcs, which
+	// it fills in when they are nil. It is required: the centroid can't
+	// tell which distance given search funcs rank by.
+	Metric mathutils.Metric
+	// Clock tells the current time for age computations. Defaults to
+	// time.Now; tests can substitute a fake clock.
+	Clock func() time.Time
+	// ZeroVecFallback makes searches involving a zero vector, as the
+	// target or among the candidates, rank by Metric with every comparison
+	// involving a zero vector measured by Euclidean distance, instead of
+	// by the search funcs. Zero vectors have no direction, so with cosine
+	// search funcs a zero target would otherwise score every candidate the
+	// same, and a zero candidate would rank as if orthogonal to any target.
+	ZeroVecFallback bool
+	// AutoNormalize makes AddPayload reject zero vectors and store the
+	// other payloads wrapped with a unit-length copy of their vector,
+	// keeping every payload on the unit sphere as in spherical k-means.
+	// The caller's vectors are left as they are; the wrapped payload is
+	// available through common.Unwrap, which is also where its optional
+	// interfaces, such as common.Timestamped, are found.
+	AutoNormalize bool
+	// CompactRatio, if positive, makes AddPayload Compact the centroid
+	// when more than this fraction of its payloads has expired, so
+	// long-lived centroids reclaim memory without an external janitor. To
+	// keep AddPayload cheap, the fraction is only checked when DataPoints
+	// is full and would otherwise have to grow. At most 1; zero, the
+	// default, disables compaction.
+	CompactRatio float64
+	// OnReject, if set, is called with every payload AddPayload rejects
+	// and one of the Reject* reasons, so callers can log or reroute
+	// payloads that would otherwise be lost.
+	OnReject func(p payloadContainer, reason string)
+	// ReservoirCap, if positive, caps the number of payloads held while
+	// keeping them representative of everything added: once the cap is
+	// reached, AddPayload keeps a new payload with the classic reservoir
+	// probability cap/seen, replacing an existing one picked with
+	// probability proportional to its similarity to the centroid vector
+	// (see DistributePayload), so redundant payloads near the centre go
+	// first and diverse ones stay. Payloads not kept are rejected with
+	// RejectSampledOut.
+	ReservoirCap int
+	// ReservoirRng drives the reservoir sampling. Defaults to a fixed
+	// seed.
+	ReservoirRng *rand.Rand
+	// Updater decides where MoveVector moves the vector, e.g.
+	// MedianUpdater for robustness to outliers. Defaults to MeanUpdater.
+	Updater Updater
+	// PayloadFactory, if set, rebuilds the payloads of decoded centroids
+	// (see UnmarshalJSON) that were encoded with a common.WirePayload type,
+	// so they come back as their concrete type.
+	PayloadFactory *common.PayloadFactory
+}
+
+// Reasons passed to NewCentroidArgs.OnReject.
+const (
+	RejectNilVec      = "nil vector"
+	RejectDimMismatch = "dimension mismatch"
+	RejectExpired     = "expired"
+	RejectZeroVec     = "zero vector"
+	RejectSampledOut  = "sampled out"
+)
+
+// NewCentroid creates a Centroid from args. Returns false if InitVec or
+// Metric is nil, if InitCap, GrowthHint or ReservoirCap is negative, or if
+// CompactRatio is outside [0, 1].
+func NewCentroid(args NewCentroidArgs) (*Centroid, bool) {
+	if args.InitVec == nil || args.InitCap < 0 || args.GrowthHint < 0 {
+		return nil, false
+	}
+	if args.CompactRatio < 0 || args.CompactRatio > 1 || args.ReservoirCap < 0 {
+		return nil, false
+	}
+	if args.Metric == nil {
+		return nil, false
+	}
+	if args.KNNSearchFunc == nil {
+		args.KNNSearchFunc = searchutils.KNNByMetric(args.Metric)
+	}
+	if args.KFNSearchFunc == nil {
+		args.KFNSearchFunc = searchutils.KFNByMetric(args.Metric)
+	}
+	if args.Clock == nil {
+		args.Clock = time.Now
+	}
+	if args.ID == "" {
+		args.ID = vecID(args.InitVec)
+	}
+	if args.ReservoirCap > 0 && args.ReservoirRng == nil {
+		args.ReservoirRng = rand.New(rand.NewSource(0))
+	}
+	if args.Updater == nil {
+		args.Updater = MeanUpdater{}
+	}
+	return &Centroid{
+		id:            args.ID,
+		vec:           append([]float64(nil), args.InitVec...),
+		DataPoints:    make([]payloadContainer, 0, args.InitCap),
+		knnSearchFunc: args.KNNSearchFunc,
+		kfnSearchFunc: args.KFNSearchFunc,
+		drainTieBreak: args.DrainTieBreak,
+		metric:        args.Metric,
+		growthHint:    args.GrowthHint,
+		clock:         args.Clock,
+		zeroFallback:  args.ZeroVecFallback,
+		autoNormalize: args.AutoNormalize,
+		compactRatio:  args.CompactRatio,
+		onReject:      args.OnReject,
+		reservoir:     reservoir{cap: args.ReservoirCap, rng: args.ReservoirRng},
+		updater:       args.Updater,
+		factory:       args.PayloadFactory,
+	}, true
+}
+
+// vecID returns a deterministic identifier for vec: the hex FNV-1a hash of
+// its elements' IEEE 754 bits.
+func vecID(vec []float64) string {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, x := range vec {
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(x))
+		h.Write(buf[:])
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// ID returns the identifier of the centroid, stable for its lifetime; see
+// NewCentroidArgs.ID.
+func (c *Centroid) ID() string {
+	return c.id
+}
+
+// Clone returns a deep copy of c, e.g. to snapshot a centroid for
+// evaluation while the original keeps changing. The vector and DataPoints
+// are copied, so adding, draining or moving on either side doesn't affect
+// the other; the payloads themselves, and the configuration (search funcs,
+// Metric, Updater and so on), are shared.
+func (c *Centroid) Clone() *Centroid {
+	clone := *c
+	clone.vec = append([]float64(nil), c.vec...)
+	clone.DataPoints = make([]payloadContainer, len(c.DataPoints), cap(c.DataPoints))
+	copy(clone.DataPoints, c.DataPoints)
+	// The tracked sum holds a slice; start the clone's afresh rather than
+	// share it.
+	clone.sum, clone.sumStale = mathutils.RunningSum{}, true
+	return &clone
+}
+
+// args returns the arguments that would create an empty centroid at vec
+// configured like c.
+func (c *Centroid) args(vec []float64) NewCentroidArgs {
+	return NewCentroidArgs{
+		InitVec:         vec,
+		GrowthHint:      c.growthHint,
+		KNNSearchFunc:   c.knnSearchFunc,
+		KFNSearchFunc:   c.kfnSearchFunc,
+		DrainTieBreak:   c.drainTieBreak,
+		Metric:          c.metric,
+		Clock:           c.clock,
+		ZeroVecFallback: c.zeroFallback,
+		AutoNormalize:   c.autoNormalize,
+		CompactRatio:    c.compactRatio,
+		OnReject:        c.onReject,
+		ReservoirCap:    c.reservoir.cap,
+		ReservoirRng:    c.reservoir.rng,
+		Updater:         c.updater,
+		PayloadFactory:  c.factory,
+	}
+}
+
+// internalArgs is args without reservoir sampling and OnReject, for
+// centroids that must take every payload they are handed, e.g. while
+// payloads are regrouped, without reporting anything to the user.
+func (c *Centroid) internalArgs(vec []float64) NewCentroidArgs {
+	args := c.args(vec)
+	args.ReservoirCap, args.ReservoirRng, args.OnReject = 0, nil, nil
+	return args
+}
+
+// AttachSearch sets the search funcs of the centroid. Search funcs can't be
+// serialized, so a decoded centroid has none until they are attached, and
+// searching it before then panics.
+func (c *Centroid) AttachSearch(knn, kfn knnSearchFunc) {
+	c.knnSearchFunc, c.kfnSearchFunc = knn, kfn
+}
+
+// AttachSearchByName is AttachSearch for search funcs ranking by the metric
+// called name (see mathutils.MetricByName), which also becomes the
+// centroid Metric. Returns false, leaving the centroid untouched, if the
+// name is unknown.
+func (c *Centroid) AttachSearchByName(name string) bool {
+	metric, ok := mathutils.MetricByName(name)
+	if !ok {
+		return false
+	}
+	c.metric = metric
+	c.AttachSearch(searchutils.KNNByMetric(metric), searchutils.KFNByMetric(metric))
+	return true
+}
+
+// errSearchUnset is the panic value of searches on a centroid without
+// search funcs.
+const errSearchUnset = "kmeans: centroid search funcs unset; call AttachSearch after decoding"
+
+// knn runs the KNN search func, or its zero-vector fallback (see
+// NewCentroidArgs.ZeroVecFallback).
+func (c *Centroid) knn(target []float64, vecs func() ([]float64, bool), k int) []int {
+	return c.search(c.knnSearchFunc, false, target, vecs, k)
+}
+
+// kfn runs the KFN search func, or its zero-vector fallback (see
+// NewCentroidArgs.ZeroVecFallback).
+func (c *Centroid) kfn(target []float64, vecs func() ([]float64, bool), k int) []int {
+	return c.search(c.kfnSearchFunc, true, target, vecs, k)
+}
+
+// search runs fn, which ranks furthest first if furthest is true. If the
+// zero-vector fallback is on and target or any of vecs is a zero vector,
+// it ranks by the centroid Metric instead, with every comparison involving
+// a zero vector measured by Euclidean distance. That needs a look at every
+// vector first, so vecs are collected.
+func (c *Centroid) search(
+	fn knnSearchFunc,
+	furthest bool,
+	target []float64,
+	vecs func() ([]float64, bool),
+	k int,
+) []int {
+	if c.zeroFallback {
+		var all [][]float64
+		zero := mathutils.IsZero(target)
+		for v, ok := vecs(); ok; v, ok = vecs() {
+			all = append(all, v)
+			// Nil vectors are skipped by searches, not zero vectors.
+			zero = zero || (v != nil && mathutils.IsZero(v))
+		}
+		vecs = mathutils.VecGenerator(all)
+		if zero && furthest {
+			return searchutils.KFNByMetric(zeroSafe(c.metric))(target, vecs, k)
+		}
+		if zero {
+			return searchutils.KNNByMetric(zeroSafe(c.metric))(target, vecs, k)
+		}
+	}
+	if fn == nil {
+		panic(errSearchUnset)
+	}
+	return fn(target, vecs, k)
+}
+
+// zeroSafe returns metric, except that comparisons involving a zero vector
+// are measured by Euclidean distance.
+func zeroSafe(metric mathutils.Metric) mathutils.Metric {
+	return func(v1, v2 []float64) (float64, error) {
+		if mathutils.IsZero(v1) || mathutils.IsZero(v2) {
+			return mathutils.EuclideanDistance(v1, v2)
+		}
+		return metric(v1, v2)
+	}
+}
+
+// Vec returns the centroid vector. The returned slice aliases the internal
+// state: modifying it modifies the centroid. Use VecCopy when the vector is
+// handed to code that may write to it.
+func (c *Centroid) Vec() []float64 {
+	return c.vec
+}
+
+// VecCopy returns a copy of the centroid vector that the caller is free to
+// modify.
+func (c *Centroid) VecCopy() []float64 {
+	return append([]float64(nil), c.vec...)
+}
+
+// stringVecLen is the number of vector elements String prints before
+// truncating.
+const stringVecLen = 8
+
+// String summarizes the centroid for logs and test failures: its ID,
+// dimension, payload count, expired payload count and vector, the latter
+// truncated to its first few elements. Payloads are not printed.
+func (c *Centroid) String() string {
+	expired := len(c.DataPoints) - c.lenLive()
+	vec := fmt.Sprint(c.vec)
+	if len(c.vec) > stringVecLen {
+		vec = fmt.Sprint(c.vec[:stringVecLen])
+		vec = fmt.Sprintf("%s ...]", vec[:len(vec)-1])
+	}
+	return fmt.Sprintf(
+		"Centroid{id=%s dim=%d payloads=%d expired=%d vec=%s}",
+		c.id, len(c.vec), len(c.DataPoints), expired, vec,
+	)
+}
+
+// Equal reports whether c and other have vectors of the same dimension that
+// differ by at most tol in every element, and hold the same number of
+// payloads. The payloads themselves and the configuration are not compared.
+// Two nil centroids are equal.
+func (c *Centroid) Equal(other *Centroid, tol float64) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if len(c.vec) != len(other.vec) || len(c.DataPoints) != len(other.DataPoints) {
+		return false
+	}
+	for i := range c.vec {
+		if math.Abs(c.vec[i]-other.vec[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// AddPayload adds p to the centroid. Returns false if p or its vector is
+// nil, if the vector dimension differs from the centroid's, if p has
+// already expired, or if the centroid normalizes payloads (see
+// NewCentroidArgs.AutoNormalize) and the vector is a zero vector. Rejected
+// payloads other than nil are passed to NewCentroidArgs.OnReject.
+func (c *Centroid) AddPayload(p payloadContainer) bool {
+	if p == nil {
+		return false
+	}
+	switch {
+	case p.Vec() == nil:
+		return c.reject(p, RejectNilVec)
+	case len(p.Vec()) != len(c.vec):
+		return c.reject(p, RejectDimMismatch)
+	case p.Expired():
+		return c.reject(p, RejectExpired)
+	case c.autoNormalize && mathutils.Norm(p.Vec()) == 0:
+		return c.reject(p, RejectZeroVec)
+	}
+	// Payloads moved from another centroid may already be wrapped.
+	if _, ok := p.(*normalizedPayload); c.autoNormalize && !ok {
+		p = newNormalizedPayload(p)
+	}
+	if c.reservoir.cap > 0 {
+		return c.addSampled(p)
+	}
+	if c.compactRatio > 0 && len(c.DataPoints) == cap(c.DataPoints) {
+		if float64(len(c.DataPoints)-c.lenLive()) > c.compactRatio*float64(len(c.DataPoints)) {
+			c.Compact()
+		}
+	}
+	if c.growthHint > 0 && len(c.DataPoints) == cap(c.DataPoints) {
+		c.Reserve(c.growthHint)
+	}
+	c.DataPoints = append(c.DataPoints, p)
+	c.sse += c.sqDistance(p)
+	c.sum.Add(p.Vec())
+	return true
+}
+
+// normalizedPayload is the payload AddPayload stores for centroids that
+// normalize payloads (see NewCentroidArgs.AutoNormalize): the added payload
+// with a unit-length copy of its vector. It implements common.Unwrapper.
+type normalizedPayload struct {
+	payloadContainer
+	vec []float64
+}
+
+// newNormalizedPayload wraps p, whose vector must not be a zero vector.
+func newNormalizedPayload(p payloadContainer) *normalizedPayload {
+	vec := append([]float64(nil), p.Vec()...)
+	mathutils.Normalize(vec)
+	return &normalizedPayload{payloadContainer: p, vec: vec}
+}
+
+func (p *normalizedPayload) Vec() []float64 { return p.vec }
+
+// Unwrap returns the payload as it was added.
+func (p *normalizedPayload) Unwrap() payloadContainer { return p.payloadContainer }
+
+// reject reports p to the OnReject callback, if any, and returns false.
+func (c *Centroid) reject(p payloadContainer, reason string) bool {
+	if c.onReject != nil {
+		c.onReject(p, reason)
+	}
+	return false
+}
+
+// Reserve grows the capacity of DataPoints, if needed, so that at least n
+// more payloads can be added without reallocating. Existing payloads are
+// kept as they are.
+func (c *Centroid) Reserve(n int) {
+	if n <= 0 || cap(c.DataPoints)-len(c.DataPoints) >= n {
+		return
+	}
+	grown := make([]payloadContainer, len(c.DataPoints), len(c.DataPoints)+n)
+	copy(grown, c.DataPoints)
+	c.DataPoints = grown
+}
+
+// rmPayload removes the payload at index, keeping the order of the rest.
+// The index is not bounds-checked.
+func (c *Centroid) rmPayload(index int) {
+	last := len(c.DataPoints) - 1
+	c.untrack(c.DataPoints[index])
+	copy(c.DataPoints[index:], c.DataPoints[index+1:])
+	c.DataPoints[last] = nil
+	c.DataPoints = c.DataPoints[:last]
+}
+
+// rmPayloadSafe is rmPayload that bounds-checks index. Returns false,
+// removing nothing, if it is out of range.
+func (c *Centroid) rmPayloadSafe(index int) bool {
+	if index < 0 || index >= len(c.DataPoints) {
+		return false
+	}
+	c.rmPayload(index)
+	return true
+}
+
+// validIndexes returns indexes, as returned by a search func, without those
+// out of range of DataPoints and without repeats, so that a faulty search
+// func can't cause a panic or a double removal.
+func (c *Centroid) validIndexes(indexes []int) []int {
+	valid := make([]int, 0, len(indexes))
+	seen := make(map[int]bool, len(indexes))
+	for _, index := range indexes {
+		if index >= 0 && index < len(c.DataPoints) && !seen[index] {
+			seen[index] = true
+			valid = append(valid, index)
+		}
+	}
+	return valid
+}
+
+// drainIndexes removes and returns the payloads at indexes, in the order
+// the indexes are given; invalid indexes are skipped (see validIndexes).
+// Removal happens in descending index order so that earlier removals don't
+// shift the payloads later ones refer to.
+func (c *Centroid) drainIndexes(indexes []int) []payloadContainer {
+	indexes = c.validIndexes(indexes)
+	drained := make([]payloadContainer, len(indexes))
+	for i, index := range indexes {
+		drained[i] = c.DataPoints[index]
+	}
+
+	desc := append([]int(nil), indexes...)
+	sort.Sort(sort.Reverse(sort.IntSlice(desc)))
+	for _, index := range desc {
+		c.rmPayloadSafe(index)
+	}
+	return drained
+}
+
+// payloadVecGenerator returns a generator over the vectors of DataPoints,
+// in order. Expired payloads yield a nil vector instead of being skipped,
+// so that generator indexes line up with DataPoints indexes; the search
+// funcs and mathutils.VecMean ignore nil vectors.
+func (c *Centroid) payloadVecGenerator() func() ([]float64, bool) {
+	i := 0
+	return func() ([]float64, bool) {
+		if i >= len(c.DataPoints) {
+			return nil, false
+		}
+		p := c.DataPoints[i]
+		i++
+		if p.Expired() {
+			return nil, true
+		}
+		return p.Vec(), true
+	}
+}
+
+// DrainUnordered removes and returns up to n payloads, taken from the end
+// of DataPoints without regard to how well they fit.
+func (c *Centroid) DrainUnordered(n int) []payloadContainer {
+	if n <= 0 {
+		return []payloadContainer{}
+	}
+	if n > len(c.DataPoints) {
+		n = len(c.DataPoints)
+	}
+	start := len(c.DataPoints) - n
+	drained := append([]payloadContainer(nil), c.DataPoints[start:]...)
+	for i := start; i < len(c.DataPoints); i++ {
+		c.untrack(c.DataPoints[i])
+		c.DataPoints[i] = nil
+	}
+	c.DataPoints = c.DataPoints[:start]
+	return drained
+}
+
+// DrainOrdered removes and returns up to n non-expired payloads that fit
+// the centroid worst according to the KFN search func, worst first. Unless
+// given explicitly, the KFN search func follows the centroid Metric; see
+// NewCentroidArgs.KFNSearchFunc. Ties are resolved with the DrainTieBreak
+// given to NewCentroid, if any.
+func (c *Centroid) DrainOrdered(n int) []payloadContainer {
+	if c.drainTieBreak == nil {
+		indexes := c.kfn(c.vec, c.payloadVecGenerator(), n)
+		return c.drainIndexes(indexes)
+	}
+	return c.drainIndexes(c.searchTieBroken(c.kfn, c.vec, n, c.drainTieBreak))
+}
+
+// searchTieBroken runs search over the payloads like the plain searches do,
+// but resolves ties with less: a ranks before b if less(a, b). Returned
+// indexes refer to DataPoints.
+func (c *Centroid) searchTieBroken(
+	search knnSearchFunc,
+	target []float64,
+	n int,
+	less func(a, b payloadContainer) bool,
+) []int {
+	// Search funcs keep generation order on ties, so presenting the
+	// payloads pre-sorted by less resolves ties by it.
+	perm := make([]int, len(c.DataPoints))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.SliceStable(perm, func(i, j int) bool {
+		return less(c.DataPoints[perm[i]], c.DataPoints[perm[j]])
+	})
+	gen := c.payloadVecGenerator()
+	vecs := make([][]float64, len(perm))
+	for i := range vecs {
+		vecs[i], _ = gen()
+	}
+	permuted := make([][]float64, len(perm))
+	for i, index := range perm {
+		permuted[i] = vecs[index]
+	}
+
+	indexes := search(target, mathutils.VecGenerator(permuted), n)
+	for i, index := range indexes {
+		indexes[i] = perm[index]
+	}
+	return indexes
+}
+
+// DrainOrderedWithScores is DrainOrdered that also returns, for each drained
+// payload, its distance to the centroid vector under the centroid Metric.
+// This lets a rebalancer judge whether drained data is worth redistributing.
+func (c *Centroid) DrainOrderedWithScores(n int) ([]payloadContainer, []float64) {
+	drained := c.DrainOrdered(n)
+	scores := make([]float64, len(drained))
+	for i, p := range drained {
+		scores[i] = c.distance(p.Vec())
+	}
+	return drained, scores
+}
+
+// distance returns the distance from the centroid vector to vec under the
+// centroid Metric, or NaN if they can't be compared.
+func (c *Centroid) distance(vec []float64) float64 {
+	d, err := c.metric(c.vec, vec)
+	if err != nil {
+		return math.NaN()
+	}
+	return d
+}
+
+// OldestFirst is a DrainTieBreak that drains older payloads first, using
+// common.Timestamped. Payloads without a timestamp go after those with one.
+func OldestFirst(a, b payloadContainer) bool {
+	ta, aok := common.Unwrap(a).(common.Timestamped)
+	tb, bok := common.Unwrap(b).(common.Timestamped)
+	if aok && bok {
+		return ta.Created().Before(tb.Created())
+	}
+	return aok && !bok
+}
+
+// NewestFirst orders newer payloads first, using common.Timestamped.
+// Payloads without a timestamp go after those with one. See
+// LookupArgs.PreferRecent.
+func NewestFirst(a, b payloadContainer) bool {
+	ta, aok := common.Unwrap(a).(common.Timestamped)
+	tb, bok := common.Unwrap(b).(common.Timestamped)
+	if aok && bok {
+		return ta.Created().After(tb.Created())
+	}
+	return aok && !bok
+}
+
+// Expire removes all expired payloads, keeping the order of the rest.
+func (c *Centroid) Expire() {
+	c.removeIf(payloadContainer.Expired)
+}
+
+// removeIf removes every payload for which rm returns true, keeping the
+// order of the rest. Returns the number removed.
+func (c *Centroid) removeIf(rm func(p payloadContainer) bool) int {
+	kept := c.DataPoints[:0]
+	for _, p := range c.DataPoints {
+		if !rm(p) {
+			kept = append(kept, p)
+		} else {
+			c.untrack(p)
+		}
+	}
+	removed := len(c.DataPoints) - len(kept)
+	for i := len(kept); i < len(c.DataPoints); i++ {
+		c.DataPoints[i] = nil
+	}
+	c.DataPoints = kept
+	return removed
+}
+
+// LenDP returns the number of payloads held, expired ones included.
+func (c *Centroid) LenDP() int {
+	return len(c.DataPoints)
+}
+
+// Cap returns the capacity of DataPoints. Draining leaves it as it was, so
+// Cap-LenDP is the slack that MemTrim or Compact would release.
+func (c *Centroid) Cap() int {
+	return cap(c.DataPoints)
+}
+
+// clear removes all payloads, keeping the capacity of DataPoints.
+func (c *Centroid) clear() {
+	for i := range c.DataPoints {
+		c.DataPoints[i] = nil
+	}
+	c.DataPoints = c.DataPoints[:0]
+	c.sse, c.sseStale = 0, false
+	c.sum, c.sumStale = mathutils.RunningSum{}, false
+}
+
+// SSE returns the sum of squared distances, under the centroid Metric,
+// from the centroid vector to each non-expired payload.
+func (c *Centroid) SSE() float64 {
+	var sum float64
+	gen := c.payloadVecGenerator()
+	for v, ok := gen(); ok; v, ok = gen() {
+		if v == nil {
+			continue
+		}
+		d := c.distance(v)
+		sum += d * d
+	}
+	return sum
+}
+
+// Inertia returns the within-cluster sum of squares: the sum of squared
+// Euclidean distances from the centroid vector to each non-expired payload.
+// It is the quantity k-means minimizes, for elbow plots and convergence
+// checks, and matches SSE for Euclidean centroids; unlike SSE it ignores
+// the centroid Metric. Payloads that can't be compared with the vector are
+// skipped.
+func (c *Centroid) Inertia() float64 {
+	var sum float64
+	gen := c.payloadVecGenerator()
+	for v, ok := gen(); ok; v, ok = gen() {
+		if d, err := mathutils.SquaredEuclideanDistance(c.vec, v); err == nil {
+			sum += d
+		}
+	}
+	return sum
+}
+
+// Radius returns the largest distance, under the centroid Metric, from the
+// centroid vector to a non-expired payload, e.g. to draw the cluster or
+// detect overlapping ones. Returns false if there are no non-expired
+// payloads that can be compared with the vector.
+func (c *Centroid) Radius() (float64, bool) {
+	radius, ok := 0., false
+	c.eachLiveDistance(func(d float64) {
+		radius, ok = math.Max(radius, d), true
+	})
+	return radius, ok
+}
+
+// MeanDistance returns the mean distance, under the centroid Metric, from
+// the centroid vector to its non-expired payloads. Returns false if there
+// are none that can be compared with the vector. See RadiusApprox for an
+// estimate that doesn't visit every payload.
+func (c *Centroid) MeanDistance() (float64, bool) {
+	var sum float64
+	n := 0
+	c.eachLiveDistance(func(d float64) {
+		sum += d
+		n++
+	})
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// eachLiveDistance calls fn with the distance from the centroid vector to
+// each non-expired payload, skipping those that can't be compared with it.
+func (c *Centroid) eachLiveDistance(fn func(d float64)) {
+	gen := c.payloadVecGenerator()
+	for v, ok := gen(); ok; v, ok = gen() {
+		if v == nil {
+			continue
+		}
+		if d := c.distance(v); !math.IsNaN(d) {
+			fn(d)
+		}
+	}
+}
+
+// RadiusApprox estimates MeanDistance from sample payloads drawn at random
+// with replacement from rng (a fixed seed if nil). It costs O(sample)
+// rather than a pass over every payload, for cheap monitoring of huge
+// centroids; a seeded rng makes the estimate reproducible. If sample covers
+// DataPoints, the mean is computed exactly instead.
+//
+// Returns false if sample is less than 1 or no non-expired payload that can
+// be compared with the centroid vector was drawn.
+func (c *Centroid) RadiusApprox(sample int, rng *rand.Rand) (float64, bool) {
+	if sample < 1 {
+		return 0, false
+	}
+	if rng == nil {
+		rng = rand.New(rand.NewSource(0))
+	}
+	exact := sample >= len(c.DataPoints)
+	if exact {
+		sample = len(c.DataPoints)
+	}
+	var sum float64
+	n := 0
+	for i := 0; i < sample; i++ {
+		p := c.DataPoints[i]
+		if !exact {
+			p = c.DataPoints[rng.Intn(len(c.DataPoints))]
+		}
+		if p.Expired() {
+			continue
+		}
+		if d := c.distance(p.Vec()); !math.IsNaN(d) {
+			sum += d
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// TrackedSSE returns SSE as maintained incrementally: adding or removing a
+// payload adjusts it in O(dim), and it is only recomputed in full on the
+// first read after MoveVector moves the vector. It matches SSE except that
+// payloads which expired after being added still count until they are
+// removed (e.g. by Expire), and that modifying DataPoints or the vector
+// directly bypasses it.
+func (c *Centroid) TrackedSSE() float64 {
+	if c.sseStale {
+		c.sse, c.sseStale = 0, false
+		for _, p := range c.DataPoints {
+			c.sse += c.sqDistance(p)
+		}
+	}
+	return c.sse
+}
+
+// sqDistance returns the squared distance from the centroid vector to p.
+func (c *Centroid) sqDistance(p payloadContainer) float64 {
+	d := c.distance(p.Vec())
+	return d * d
+}
+
+// untrack takes p, which is about to be removed, out of the tracked SSE and
+// the tracked sum.
+func (c *Centroid) untrack(p payloadContainer) {
+	c.sumStale = true
+	c.sse -= c.sqDistance(p)
+	if c.sse < 0 || len(c.DataPoints) == 1 {
+		// Rounding residue.
+		c.sse = 0
+	}
+}
+
+// lenLive returns the number of non-expired payloads held.
+func (c *Centroid) lenLive() int {
+	n := 0
+	for _, p := range c.DataPoints {
+		if !p.Expired() {
+			n++
+		}
+	}
+	return n
+}
+
+// MemTrim reallocates DataPoints so its capacity matches its length,
+// releasing memory left over from drains.
+func (c *Centroid) MemTrim() {
+	trimmed := make([]payloadContainer, len(c.DataPoints))
+	copy(trimmed, c.DataPoints)
+	c.DataPoints = trimmed
+}
+
+// Compact removes expired payloads and trims the capacity of DataPoints to
+// what remains (see Expire and MemTrim). Returns the number of payloads
+// removed.
+func (c *Centroid) Compact() int {
+	removed := c.removeIf(payloadContainer.Expired)
+	c.MemTrim()
+	return removed
+}
+
+// MoveVector moves the centroid vector to represent its non-expired
+// payloads, by default to their mean (see NewCentroidArgs.Updater). Returns
+// false, leaving the vector unchanged, if there are none or the Updater
+// fails.
+//
+// With the default MeanUpdater, the mean comes from a sum kept up to date
+// by AddPayload, so as long as payloads are only added, a move costs
+// O(dim) plus an expiry check per payload. After payloads are removed (by
+// draining, Expire etc.) or while expired ones are held, the next move
+// takes a full pass over the payloads. Modifying DataPoints or the payload
+// vectors directly bypasses the sum, as it does TrackedSSE.
+func (c *Centroid) MoveVector() bool {
+	if _, ok := c.updater.(MeanUpdater); ok && c.moveToSum() {
+		c.sseStale = true
+		return true
+	}
+	if !c.updater.Update(c) {
+		return false
+	}
+	c.sseStale = true
+	c.sum, c.sumStale = mathutils.RunningSum{}, false
+	for _, p := range c.DataPoints {
+		c.sum.Add(p.Vec())
+	}
+	return true
+}
+
+// Merge moves the payloads of other into c, e.g. to consolidate two
+// centroids that drifted close together, and then moves c's vector with
+// MoveVector, by default to the mean of the union. Payloads go through
+// AddPayload, so expired ones are dropped on the way. On success other is
+// left empty. Returns false, changing neither, if other is nil or c itself,
+// or if their dimensions differ.
+func (c *Centroid) Merge(other *Centroid) bool {
+	if other == nil || other == c || len(other.vec) != len(c.vec) {
+		return false
+	}
+	c.Reserve(len(other.DataPoints))
+	for _, p := range other.DataPoints {
+		c.AddPayload(p)
+	}
+	other.clear()
+	c.MoveVector()
+	return true
+}
+
+// Split bisects c, e.g. once it has grown too large, into two new centroids
+// configured like c, each holding one group of c's non-expired payloads.
+// The groups come from 2-means on the payloads under the centroid Metric,
+// seeded with the payload furthest from c and the payload furthest from
+// that one, which separates c along its widest spread. It is the
+// counterpart of Merge: on success c is left empty, and callers replace it
+// with the halves. Together the halves hold every non-expired payload of c:
+// reservoir sampling (see NewCentroidArgs.ReservoirCap) and OnReject only
+// apply to payloads added after the split.
+//
+// Returns false, leaving c untouched, if c has fewer than two non-expired
+// payloads or they can't be split into two non-empty groups, e.g. because
+// they all share a vector.
+func (c *Centroid) Split() (*Centroid, *Centroid, bool) {
+	live := make([]payloadContainer, 0, len(c.DataPoints))
+	for _, p := range c.DataPoints {
+		if !p.Expired() {
+			live = append(live, p)
+		}
+	}
+	if len(live) < 2 {
+		return nil, nil, false
+	}
+	a := c.furthest(c.vec, live)
+	b := c.furthest(live[a].Vec(), live)
+	if d, err := c.metric(live[a].Vec(), live[b].Vec()); err != nil || !(d > 0) {
+		return nil, nil, false
+	}
+
+	halves := make([]*Centroid, 2)
+	for i, seed := range []int{a, b} {
+		half, ok := NewCentroid(c.internalArgs(live[seed].Vec()))
+		if !ok {
+			return nil, nil, false
+		}
+		halves[i] = half
+	}
+	labels := make([]int, len(live))
+	for i := range labels {
+		labels[i] = -1
+	}
+	for iter := 1; ; iter++ {
+		changed := assign(halves, live, labels, c.metric, 0)
+		if changed == 0 || iter == defaultMaxIter {
+			break
+		}
+		for _, half := range halves {
+			half.MoveVector()
+		}
+	}
+	if halves[0].LenDP() == 0 || halves[1].LenDP() == 0 {
+		return nil, nil, false
+	}
+	for _, half := range halves {
+		half.onReject = c.onReject
+		if c.reservoir.cap > 0 {
+			half.reservoir = reservoir{cap: c.reservoir.cap, rng: c.reservoir.rng, seen: half.LenDP()}
+		}
+	}
+	c.clear()
+	return halves[0], halves[1], true
+}
+
+// furthest returns the index of the payload furthest from vec under the
+// centroid Metric, lowest index on ties. Payloads that can't be compared
+// with vec are never furthest.
+func (c *Centroid) furthest(vec []float64, payloads []payloadContainer) int {
+	best, bestDist := 0, math.Inf(-1)
+	for i, p := range payloads {
+		if d, err := c.metric(vec, p.Vec()); err == nil && d > bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// moveToSum moves the centroid vector to the mean of the tracked sum.
+// Returns false, leaving the vector unchanged, if the sum doesn't match the
+// non-expired payloads or is empty.
+func (c *Centroid) moveToSum() bool {
+	if c.sumStale || c.sum.Count() != len(c.DataPoints) || c.lenLive() != len(c.DataPoints) {
+		return false
+	}
+	mean, ok := c.sum.Mean()
+	if !ok {
+		return false
+	}
+	c.vec = mean
+	return true
+}
+
+// KNNLookup returns up to k non-expired payloads that best match vec
+// according to the KNN search func, best first. If drain is true the
+// returned payloads are also removed from the centroid. See Lookup for more
+// options.
+func (c *Centroid) KNNLookup(vec []float64, k int, drain bool) []payloadContainer {
+	return c.Lookup(LookupArgs{Vec: vec, K: k, Drain: drain})
+}
+
+// NearestPayload returns the non-expired payload that best matches vec
+// according to the KNN search func, without draining it. It is
+// KNNLookup(vec, 1, false) for the common single-neighbour query, and
+// returns false if there is no such payload.
+func (c *Centroid) NearestPayload(vec []float64) (payloadContainer, bool) {
+	found := c.KNNLookup(vec, 1, false)
+	if len(found) == 0 {
+		return nil, false
+	}
+	return found[0], true
+}
+
+// LookupArgs is the argument set for Centroid.Lookup.
+type LookupArgs struct {
+	// Vec is the vector to match payloads against.
+	Vec []float64
+	// K is the maximum number of payloads returned.
+	K int
+	// Drain removes the returned payloads from the centroid.
+	Drain bool
+	// PreferRecent ranks newer payloads first among those that match Vec
+	// equally well, using common.Timestamped (see NewestFirst), so that
+	// fresher data wins ties. Without it, ties keep DataPoints order.
+	PreferRecent bool
+}
+
+// Lookup is KNNLookup configured by args.
+func (c *Centroid) Lookup(args LookupArgs) []payloadContainer {
+	var indexes []int
+	if args.PreferRecent {
+		indexes = c.searchTieBroken(c.knn, args.Vec, args.K, NewestFirst)
+	} else {
+		indexes = c.knn(args.Vec, c.payloadVecGenerator(), args.K)
+	}
+	if args.Drain {
+		return c.drainIndexes(indexes)
+	}
+	indexes = c.validIndexes(indexes)
+	result := make([]payloadContainer, len(indexes))
+	for i, index := range indexes {
+		result[i] = c.DataPoints[index]
+	}
+	return result
+}
+
+// DistributePayload drains up to n payloads with DrainOrdered and hands
+// each one to the receiver whose vector matches it best according to the
+// KNN search func. The centroid itself may be among the receivers, and
+// receivers that implement HealthReporter and report unhealthy are skipped.
+//
+// A payload only leaves if its similarity to the best receiver is at least
+// minSim, so poor matches stay local instead of being scattered to
+// unsuitable receivers. Similarity is 1/(1+d) for the distance d under the
+// centroid Metric: 1 for identical vectors, falling towards 0 with
+// distance. A minSim of 0 or less lets every payload go.
+//
+// Payloads that stay or can't be delivered are added back to the centroid;
+// if that fails too (e.g. the payload expired in the meantime) the payload
+// is dropped.
+func (c *Centroid) DistributePayload(receivers []PayloadReceiver, n int, minSim float64) {
+	c.Distribute(DistributeArgs{Receivers: receivers, N: n, MinScore: minSim})
+}
+
+// DistributeArgs is the argument set for Centroid.Distribute.
+type DistributeArgs struct {
+	// Receivers are the candidates for the drained payloads.
+	Receivers []PayloadReceiver
+	// N is the maximum number of payloads drained.
+	N int
+	// MinScore is the acceptance score: a payload only goes to the best
+	// receiver if its similarity to it is at least MinScore.
+	MinScore float64
+	// Observe, if not nil, is called for every drained payload with the
+	// index in Receivers of the best receiver, its similarity score, and
+	// whether the payload was delivered. The index is -1, and the score 0,
+	// if no receiver could be compared with the payload.
+	Observe func(p payloadContainer, receiver int, score float64, delivered bool)
+}
+
+// Distribute is DistributePayload configured by args, which also makes the
+// choice made for each payload observable, e.g. for logging.
+func (c *Centroid) Distribute(args DistributeArgs) {
+	if len(args.Receivers) == 0 {
+		return
+	}
+	for _, p := range c.DrainOrdered(args.N) {
+		receiver, score := -1, 0.0
+		if best := c.knn(p.Vec(), receiverVecGenerator(args.Receivers), 1); len(best) == 1 {
+			receiver = best[0]
+			score = similarity(c.metric, p.Vec(), args.Receivers[receiver].Vec())
+		}
+		delivered := receiver >= 0 && score >= args.MinScore && args.Receivers[receiver].AddPayload(p)
+		if args.Observe != nil {
+			args.Observe(p, receiver, score, delivered)
+		}
+		if !delivered {
+			c.AddPayload(p)
+		}
+	}
+}
+
+// similarity maps the distance between v1 and v2 under metric into (0, 1]
+// as 1/(1+d). Vectors that can't be compared have similarity 0.
+func similarity(metric mathutils.Metric, v1, v2 []float64) float64 {
+	d, err := metric(v1, v2)
+	if err != nil {
+		return 0
+	}
+	return 1 / (1 + d)
+}
+
+// receiverVecGenerator returns a generator over the vectors of receivers.
+// Unhealthy receivers (see HealthReporter) yield nil, which search funcs
+// skip, so indexes stay aligned with receivers.
+func receiverVecGenerator(receivers []PayloadReceiver) func() ([]float64, bool) {
+	i := 0
+	return func() ([]float64, bool) {
+		if i >= len(receivers) {
+			return nil, false
+		}
+		i++
+		if h, ok := receivers[i-1].(HealthReporter); ok && !h.Healthy() {
+			return nil, true
+		}
+		return receivers[i-1].Vec(), true
+	}
+}
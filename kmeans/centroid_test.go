@@ -0,0 +1,1232 @@
+package kmeans
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// testPayload is a minimal payloadContainer for tests.
+type testPayload struct {
+	vec     []float64
+	expired bool
+}
+
+func (p *testPayload) Vec() []float64 { return p.vec }
+func (p *testPayload) Expired() bool  { return p.expired }
+
+// timedPayload is a testPayload implementing common.Timestamped.
+type timedPayload struct {
+	testPayload
+	created time.Time
+}
+
+func (p *timedPayload) Created() time.Time { return p.created }
+
+// newTestCentroid returns a Euclidean centroid at vec holding payloads with
+// the given vectors.
+func newTestCentroid(t *testing.T, vec []float64, dps ...[]float64) *Centroid {
+	t.Helper()
+	c, ok := NewCentroid(NewCentroidArgs{
+		InitVec:       vec,
+		InitCap:       len(dps),
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+	})
+	if !ok {
+		t.Fatal("failed to create centroid")
+	}
+	for _, dp := range dps {
+		if !c.AddPayload(&testPayload{vec: dp}) {
+			t.Fatalf("failed to add payload %v", dp)
+		}
+	}
+	return c
+}
+
+// dpVecs returns the vectors of c.DataPoints.
+func dpVecs(c *Centroid) [][]float64 {
+	vecs := make([][]float64, len(c.DataPoints))
+	for i, p := range c.DataPoints {
+		vecs[i] = p.Vec()
+	}
+	return vecs
+}
+
+func TestNewCentroidInvalid(t *testing.T) {
+	valid := NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+	}
+	tests := map[string]func(a *NewCentroidArgs){
+		"nil vec":        func(a *NewCentroidArgs) { a.InitVec = nil },
+		"negative cap":   func(a *NewCentroidArgs) { a.InitCap = -1 },
+		"negative hint":  func(a *NewCentroidArgs) { a.GrowthHint = -1 },
+		"nil metric":     func(a *NewCentroidArgs) { a.Metric = nil },
+		"nil knn":        func(a *NewCentroidArgs) { a.KNNSearchFunc, a.Metric = nil, nil },
+		"nil kfn":        func(a *NewCentroidArgs) { a.KFNSearchFunc, a.Metric = nil, nil },
+		"negative ratio": func(a *NewCentroidArgs) { a.CompactRatio = -0.1 },
+		"ratio above 1":  func(a *NewCentroidArgs) { a.CompactRatio = 1.1 },
+	}
+	for name, mutate := range tests {
+		args := valid
+		mutate(&args)
+		if _, ok := NewCentroid(args); ok {
+			t.Errorf("%s: expected failure", name)
+		}
+	}
+}
+
+func TestCentroidAddPayload(t *testing.T) {
+	c := newTestCentroid(t, []float64{0, 0})
+	if c.AddPayload(nil) {
+		t.Error("accepted nil payload")
+	}
+	if c.AddPayload(&testPayload{vec: []float64{1}}) {
+		t.Error("accepted payload of wrong dimension")
+	}
+	if c.AddPayload(&testPayload{vec: []float64{1, 1}, expired: true}) {
+		t.Error("accepted expired payload")
+	}
+	if !c.AddPayload(&testPayload{vec: []float64{1, 1}}) {
+		t.Error("rejected valid payload")
+	}
+	if c.LenDP() != 1 {
+		t.Errorf("got LenDP %d, want 1", c.LenDP())
+	}
+}
+
+func TestCentroidOnReject(t *testing.T) {
+	var got []string
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0, 0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		AutoNormalize: true,
+		OnReject: func(p payloadContainer, reason string) {
+			got = append(got, reason)
+		},
+	})
+	c.AddPayload(nil)
+	c.AddPayload(&testPayload{vec: []float64{1}})
+	c.AddPayload(&testPayload{vec: []float64{1, 1}, expired: true})
+	c.AddPayload(&testPayload{})
+	c.AddPayload(&testPayload{vec: []float64{0, 0}})
+	c.AddPayload(&testPayload{vec: []float64{1, 1}})
+
+	want := []string{RejectDimMismatch, RejectExpired, RejectNilVec, RejectZeroVec}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got reasons %q, want %q", got, want)
+	}
+}
+
+func TestCentroidVecCopy(t *testing.T) {
+	c := newTestCentroid(t, []float64{1, 2})
+
+	cp := c.VecCopy()
+	cp[0] = 99
+	if c.Vec()[0] != 1 {
+		t.Errorf("mutating VecCopy changed the centroid: %v", c.Vec())
+	}
+
+	c.Vec()[0] = 99
+	if c.Vec()[0] != 99 {
+		t.Errorf("mutating Vec did not change the centroid: %v", c.Vec())
+	}
+}
+
+func TestCentroidClone(t *testing.T) {
+	c := newTestCentroid(t, []float64{0, 0}, []float64{1, 2}, []float64{3, 4})
+	clone := c.Clone()
+	if !clone.Equal(c, 0) || clone.ID() != c.ID() {
+		t.Fatalf("clone %v differs from %v", clone, c)
+	}
+
+	clone.AddPayload(&testPayload{vec: []float64{5, 6}})
+	clone.MoveVector()
+	clone.DataPoints[0] = &testPayload{vec: []float64{9, 9}}
+	if c.LenDP() != 2 {
+		t.Errorf("original LenDP changed to %d", c.LenDP())
+	}
+	if want := [][]float64{{1, 2}, {3, 4}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("original payloads changed to %v", dpVecs(c))
+	}
+	if want := []float64{0, 0}; !reflect.DeepEqual(c.Vec(), want) {
+		t.Errorf("original vec changed to %v", c.Vec())
+	}
+
+	c.MoveVector()
+	if want := []float64{2, 3}; !reflect.DeepEqual(c.Vec(), want) {
+		t.Errorf("original moved to %v, want %v", c.Vec(), want)
+	}
+	if want := []float64{3, 4}; !reflect.DeepEqual(clone.Vec(), want) {
+		t.Errorf("clone moved to %v, want %v", clone.Vec(), want)
+	}
+	if found := clone.KNNLookup([]float64{9, 9}, 1, false); len(found) != 1 {
+		t.Error("clone lost its search funcs")
+	}
+}
+
+func TestCentroidEqual(t *testing.T) {
+	c := newTestCentroid(t, []float64{1, 2}, []float64{0, 0})
+	tests := []struct {
+		name  string
+		other *Centroid
+		tol   float64
+		want  bool
+	}{
+		{"exact", newTestCentroid(t, []float64{1, 2}, []float64{5, 5}), 0, true},
+		{"within tol", newTestCentroid(t, []float64{1.05, 1.95}, []float64{0, 0}), 0.1, true},
+		{"beyond tol", newTestCentroid(t, []float64{1.2, 2}, []float64{0, 0}), 0.1, false},
+		{"payload count", newTestCentroid(t, []float64{1, 2}), 0, false},
+		{"dimension", newTestCentroid(t, []float64{1, 2, 0}, []float64{0, 0, 0}), 1, false},
+		{"nil", nil, 0, false},
+	}
+	for _, tt := range tests {
+		if got := c.Equal(tt.other, tt.tol); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(*Centroid)(nil).Equal(nil, 0) {
+		t.Error("nil centroids not equal")
+	}
+}
+
+func TestCentroidString(t *testing.T) {
+	c := newTestCentroid(t, []float64{1, 2}, []float64{0, 0}, []float64{1, 1}, []float64{2, 2})
+	c.DataPoints[0].(*testPayload).expired = true
+	got := c.String()
+	for _, want := range []string{"dim=2", "payloads=3", "expired=1", "vec=[1 2]"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q does not contain %q", got, want)
+		}
+	}
+
+	long := newTestCentroid(t, make([]float64, 100))
+	if got, want := long.String(), "vec=[0 0 0 0 0 0 0 0 ...]}"; !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, want suffix %q", got, want)
+	}
+}
+
+func TestCentroidDrainUnordered(t *testing.T) {
+	c := newTestCentroid(t, []float64{0}, []float64{1}, []float64{2}, []float64{3})
+	drained := c.DrainUnordered(2)
+	if len(drained) != 2 || c.LenDP() != 1 {
+		t.Fatalf("got %d drained and %d left, want 2 and 1", len(drained), c.LenDP())
+	}
+	if got := c.DrainUnordered(5); len(got) != 1 || c.LenDP() != 0 {
+		t.Errorf("over-drain: got %d drained and %d left", len(got), c.LenDP())
+	}
+}
+
+func TestCentroidDrainOrdered(t *testing.T) {
+	c := newTestCentroid(t, []float64{0}, []float64{1}, []float64{5}, []float64{2}, []float64{9})
+	drained := c.DrainOrdered(2)
+
+	got := [][]float64{drained[0].Vec(), drained[1].Vec()}
+	if want := [][]float64{{9}, {5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("drained %v, want %v", got, want)
+	}
+	if want := [][]float64{{1}, {2}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("remaining %v, want %v", dpVecs(c), want)
+	}
+}
+
+func TestCentroidDrainOrderedUnsortedIndexes(t *testing.T) {
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: func([]float64, func() ([]float64, bool), int) []int {
+			return []int{2, 0, 3}
+		},
+		Metric: mathutils.EuclideanDistance,
+	})
+	for _, x := range []float64{10, 11, 12, 13, 14} {
+		c.AddPayload(&testPayload{vec: []float64{x}})
+	}
+	drained := c.DrainOrdered(3)
+	if want := [][]float64{{12}, {10}, {13}}; !reflect.DeepEqual(payloadVecs(drained), want) {
+		t.Errorf("drained %v, want %v", payloadVecs(drained), want)
+	}
+	if want := [][]float64{{11}, {14}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("remaining %v, want %v", dpVecs(c), want)
+	}
+}
+
+func TestCentroidKNNLookup(t *testing.T) {
+	c := newTestCentroid(t, []float64{0}, []float64{1}, []float64{5}, []float64{2}, []float64{9})
+
+	found := c.KNNLookup([]float64{4}, 2, false)
+	if len(found) != 2 || found[0].Vec()[0] != 5 || found[1].Vec()[0] != 2 {
+		t.Fatalf("unexpected lookup result")
+	}
+	if c.LenDP() != 4 {
+		t.Errorf("non-draining lookup changed LenDP to %d", c.LenDP())
+	}
+
+	c.KNNLookup([]float64{4}, 2, true)
+	if want := [][]float64{{1}, {9}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("remaining %v, want %v", dpVecs(c), want)
+	}
+}
+
+func TestCentroidNearestPayload(t *testing.T) {
+	c := newTestCentroid(t, []float64{0})
+	if _, ok := c.NearestPayload([]float64{4}); ok {
+		t.Error("found a payload in an empty centroid")
+	}
+
+	stale := &testPayload{vec: []float64{4}}
+	c.AddPayload(stale)
+	for _, v := range [][]float64{{1}, {5}, {2}, {9}} {
+		c.AddPayload(&testPayload{vec: v})
+	}
+	stale.expired = true
+
+	got, ok := c.NearestPayload([]float64{4})
+	if !ok || got != c.KNNLookup([]float64{4}, 1, false)[0] {
+		t.Fatalf("got %v, %v, want KNNLookup's first match", got, ok)
+	}
+	if got.Vec()[0] != 5 {
+		t.Errorf("got %v, want the closest non-expired payload {5}", got.Vec())
+	}
+	if c.LenDP() != 5 {
+		t.Errorf("lookup changed LenDP to %d", c.LenDP())
+	}
+}
+
+func TestCentroidKNNLookupDrainUnsortedIndexes(t *testing.T) {
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec: []float64{0},
+		KNNSearchFunc: func([]float64, func() ([]float64, bool), int) []int {
+			return []int{3, 0, 4}
+		},
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+	})
+	for _, x := range []float64{10, 11, 12, 13, 14, 15} {
+		c.AddPayload(&testPayload{vec: []float64{x}})
+	}
+	found := c.KNNLookup([]float64{0}, 3, true)
+	if want := [][]float64{{13}, {10}, {14}}; !reflect.DeepEqual(payloadVecs(found), want) {
+		t.Errorf("found %v, want %v", payloadVecs(found), want)
+	}
+	if want := [][]float64{{11}, {12}, {15}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("remaining %v, want %v", dpVecs(c), want)
+	}
+}
+
+func TestCentroidInvalidSearchIndexes(t *testing.T) {
+	faulty := func([]float64, func() ([]float64, bool), int) []int {
+		return []int{7, 1, -1, 1, 3}
+	}
+	newCentroid := func() *Centroid {
+		c, _ := NewCentroid(NewCentroidArgs{
+			InitVec:       []float64{0},
+			KNNSearchFunc: faulty,
+			KFNSearchFunc: faulty,
+			Metric:        mathutils.EuclideanDistance,
+		})
+		for _, x := range []float64{10, 11, 12, 13} {
+			c.AddPayload(&testPayload{vec: []float64{x}})
+		}
+		return c
+	}
+	want := [][]float64{{11}, {13}}
+	remaining := [][]float64{{10}, {12}}
+
+	c := newCentroid()
+	if got := payloadVecs(c.KNNLookup([]float64{0}, 2, false)); !reflect.DeepEqual(got, want) {
+		t.Errorf("lookup: got %v, want %v", got, want)
+	}
+	if got := payloadVecs(c.KNNLookup([]float64{0}, 2, true)); !reflect.DeepEqual(got, want) {
+		t.Errorf("drained lookup: got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(dpVecs(c), remaining) {
+		t.Errorf("lookup: remaining %v, want %v", dpVecs(c), remaining)
+	}
+
+	c = newCentroid()
+	if got := payloadVecs(c.DrainOrdered(2)); !reflect.DeepEqual(got, want) {
+		t.Errorf("drain: got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(dpVecs(c), remaining) {
+		t.Errorf("drain: remaining %v, want %v", dpVecs(c), remaining)
+	}
+	if c.rmPayloadSafe(2) || c.rmPayloadSafe(-1) || c.LenDP() != 2 {
+		t.Errorf("removed out of range index")
+	}
+
+	c = newCentroid()
+	if got := payloadVecs(c.KNNLookupDiverse([]float64{0}, 2, 1, true)); !reflect.DeepEqual(got, want) {
+		t.Errorf("diverse lookup: got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(dpVecs(c), remaining) {
+		t.Errorf("diverse lookup: remaining %v, want %v", dpVecs(c), remaining)
+	}
+}
+
+func TestCentroidKNNLookupDuplicates(t *testing.T) {
+	c := newTestCentroid(t, []float64{0}, []float64{3}, []float64{3}, []float64{3})
+	want := searchutils.KNNEuc([]float64{1}, c.payloadVecGenerator(), 10)
+	found := c.KNNLookup([]float64{1}, 10, false)
+	if len(found) != 3 || len(want) != 3 {
+		t.Fatalf("got %d payloads, search funcs %d, want 3", len(found), len(want))
+	}
+	for i, index := range want {
+		if found[i] != c.DataPoints[index] {
+			t.Errorf("result %d: got a different payload than the search func", i)
+		}
+	}
+	if drained := c.KNNLookup([]float64{1}, 10, true); len(drained) != 3 || c.LenDP() != 0 {
+		t.Errorf("drained %d, %d left, want 3, 0", len(drained), c.LenDP())
+	}
+}
+
+func TestCentroidLookupPreferRecent(t *testing.T) {
+	now := time.Now()
+	c := newTestCentroid(t, []float64{0})
+	// Both payloads are at distance 1 from the query; the older one
+	// comes first in DataPoints.
+	older := &timedPayload{testPayload{vec: []float64{1}}, now.Add(-time.Hour)}
+	newer := &timedPayload{testPayload{vec: []float64{-1}}, now}
+	c.AddPayload(older)
+	c.AddPayload(newer)
+
+	got := c.Lookup(LookupArgs{Vec: []float64{0}, K: 1})
+	if len(got) != 1 || got[0] != older {
+		t.Errorf("plain: got %v, want the older payload", got)
+	}
+	got = c.Lookup(LookupArgs{Vec: []float64{0}, K: 1, PreferRecent: true})
+	if len(got) != 1 || got[0] != newer {
+		t.Errorf("PreferRecent: got %v, want the newer payload", got)
+	}
+
+	// Recency only breaks ties.
+	c.AddPayload(&timedPayload{testPayload{vec: []float64{2}}, now.Add(time.Hour)})
+	got = c.Lookup(LookupArgs{Vec: []float64{0}, K: 3, PreferRecent: true, Drain: true})
+	if len(got) != 3 || got[0] != newer || got[1] != older {
+		t.Errorf("PreferRecent: got %v, want newer, older, newest", got)
+	}
+	if c.LenDP() != 0 {
+		t.Errorf("drain left %d payloads", c.LenDP())
+	}
+}
+
+func TestCentroidAttachSearch(t *testing.T) {
+	// A centroid as decoding leaves it: state, but no search funcs.
+	decoded := func() *Centroid {
+		c := &Centroid{vec: []float64{0}}
+		for _, v := range []float64{3, -1, 2} {
+			c.DataPoints = append(c.DataPoints, &testPayload{vec: []float64{v}})
+		}
+		return c
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "AttachSearch") {
+				t.Errorf("got panic %v, want one naming AttachSearch", r)
+			}
+		}()
+		decoded().KNNLookup([]float64{0}, 1, false)
+	}()
+
+	c := decoded()
+	c.AttachSearch(searchutils.KNNEuc, searchutils.KFNEuc)
+	if got := payloadVecs(c.KNNLookup([]float64{0}, 1, false)); !reflect.DeepEqual(got, [][]float64{{-1}}) {
+		t.Errorf("KNNLookup: got %v", got)
+	}
+
+	c = decoded()
+	if c.AttachSearchByName("hamming") {
+		t.Error("attached unknown metric")
+	}
+	if !c.AttachSearchByName("manhattan") {
+		t.Fatal("failed to attach manhattan")
+	}
+	if got := payloadVecs(c.DrainOrdered(1)); !reflect.DeepEqual(got, [][]float64{{3}}) {
+		t.Errorf("DrainOrdered: got %v", got)
+	}
+}
+
+func TestCentroidExpire(t *testing.T) {
+	c := newTestCentroid(t, []float64{0}, []float64{1}, []float64{2}, []float64{3})
+	c.DataPoints[1].(*testPayload).expired = true
+	c.Expire()
+	if want := [][]float64{{1}, {3}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("remaining %v, want %v", dpVecs(c), want)
+	}
+}
+
+func TestCentroidMoveVector(t *testing.T) {
+	c := newTestCentroid(t, []float64{0, 0})
+	if c.MoveVector() {
+		t.Error("moved without payloads")
+	}
+
+	c = newTestCentroid(t, []float64{0, 0}, []float64{1, 2}, []float64{3, 4}, []float64{100, 100})
+	c.DataPoints[2].(*testPayload).expired = true
+	if !c.MoveVector() {
+		t.Fatal("failed to move")
+	}
+	if want := []float64{2, 3}; !reflect.DeepEqual(c.Vec(), want) {
+		t.Errorf("got vec %v, want %v", c.Vec(), want)
+	}
+}
+
+func TestCentroidMoveVectorIncremental(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	c := newTestCentroid(t, []float64{0, 0, 0})
+	for step := 0; step < 300; step++ {
+		for i := rng.Intn(4); i > 0; i-- {
+			vec := []float64{rng.NormFloat64(), rng.NormFloat64() * 100, rng.Float64()}
+			c.AddPayload(&testPayload{vec: vec})
+		}
+		switch r := rng.Intn(20); {
+		case r == 0:
+			c.DrainUnordered(2)
+		case r == 1:
+			c.DrainOrdered(1)
+		case r == 2 && c.LenDP() > 0:
+			c.DataPoints[rng.Intn(c.LenDP())].(*testPayload).expired = true
+		case r == 3:
+			c.Expire()
+		}
+
+		want, ok := mathutils.VecMean(c.payloadVecGenerator())
+		if got := c.MoveVector(); got != ok {
+			t.Fatalf("step %d: moved %v, want %v", step, got, ok)
+		}
+		if !ok {
+			continue
+		}
+		for i := range want {
+			if math.Abs(c.Vec()[i]-want[i]) > 1e-9 {
+				t.Fatalf("step %d: got vec %v, batch mean %v", step, c.Vec(), want)
+			}
+		}
+		// Tracked SSE counts held expired payloads; see TrackedSSE.
+		if c.lenLive() < c.LenDP() {
+			continue
+		}
+		if got, want := c.TrackedSSE(), c.SSE(); math.Abs(got-want) > 1e-6*want {
+			t.Fatalf("step %d: tracked SSE %v, fresh %v", step, got, want)
+		}
+	}
+}
+
+func TestCentroidMerge(t *testing.T) {
+	a := newTestCentroid(t, []float64{0, 0}, []float64{1, 2}, []float64{3, 0})
+	b := newTestCentroid(t, []float64{5, 5}, []float64{4, 4}, []float64{6, 8}, []float64{100, 100})
+	b.DataPoints[2].(*testPayload).expired = true
+	union := append(dpVecs(a), dpVecs(b)[:2]...)
+
+	if a.Merge(nil) || a.Merge(a) || a.Merge(newTestCentroid(t, []float64{0})) {
+		t.Error("merged invalid centroid")
+	}
+	if !a.Merge(b) {
+		t.Fatal("failed to merge")
+	}
+	if !reflect.DeepEqual(dpVecs(a), union) {
+		t.Errorf("got payloads %v, want %v", dpVecs(a), union)
+	}
+	want, _ := mathutils.VecMean(mathutils.VecGenerator(union))
+	if !reflect.DeepEqual(a.Vec(), want) {
+		t.Errorf("got vec %v, want %v", a.Vec(), want)
+	}
+	if b.LenDP() != 0 {
+		t.Errorf("other kept %d payloads", b.LenDP())
+	}
+}
+
+func TestCentroidSplit(t *testing.T) {
+	left := [][]float64{{0, 0}, {1, 0}, {0, 1}, {1, 1}}
+	right := [][]float64{{20, 20}, {21, 20}, {20, 21}}
+	vecs := [][]float64{left[0], right[0], left[1], left[2], right[1], left[3], right[2]}
+	c := newTestCentroid(t, []float64{9, 9}, vecs...)
+	c.AddPayload(&testPayload{vec: []float64{-50, -50}})
+	c.DataPoints[len(vecs)].(*testPayload).expired = true
+
+	a, b, ok := c.Split()
+	if !ok {
+		t.Fatal("failed to split")
+	}
+	if dpVecs(a)[0][0] > dpVecs(b)[0][0] {
+		a, b = b, a
+	}
+	if !reflect.DeepEqual(dpVecs(a), left) || !reflect.DeepEqual(dpVecs(b), right) {
+		t.Errorf("got groups %v and %v, want %v and %v", dpVecs(a), dpVecs(b), left, right)
+	}
+	if want := []float64{0.5, 0.5}; !reflect.DeepEqual(a.Vec(), want) {
+		t.Errorf("got vec %v, want %v", a.Vec(), want)
+	}
+	if c.LenDP() != 0 {
+		t.Errorf("split centroid kept %d payloads", c.LenDP())
+	}
+
+	tests := map[string]*Centroid{
+		"empty":     newTestCentroid(t, []float64{0}),
+		"single":    newTestCentroid(t, []float64{0}, []float64{1}),
+		"identical": newTestCentroid(t, []float64{0}, []float64{1}, []float64{1}, []float64{1}),
+	}
+	for name, c := range tests {
+		before := c.LenDP()
+		if _, _, ok := c.Split(); ok || c.LenDP() != before {
+			t.Errorf("%s: got ok %v, %d payloads left, want false, %d", name, ok, c.LenDP(), before)
+		}
+	}
+}
+
+func TestCentroidSplitReservoir(t *testing.T) {
+	var rejected int
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		ReservoirCap:  2,
+		OnReject:      func(payloadContainer, string) { rejected++ },
+	})
+	// More payloads than the cap, as a decoded centroid can hold; each
+	// half gets more than the cap too.
+	for _, x := range []float64{0, 1, 2, 20, 21, 22} {
+		c.DataPoints = append(c.DataPoints, &testPayload{vec: []float64{x}})
+	}
+	live := dpVecs(c)
+
+	a, b, ok := c.Split()
+	if !ok {
+		t.Fatal("failed to split")
+	}
+	got := append(dpVecs(a), dpVecs(b)...)
+	sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+	if !reflect.DeepEqual(got, live) {
+		t.Errorf("halves hold %v, want %v", got, live)
+	}
+	if rejected != 0 {
+		t.Errorf("split rejected %d payloads", rejected)
+	}
+}
+
+func TestCentroidMemTrim(t *testing.T) {
+	c := newTestCentroid(t, []float64{0}, []float64{1}, []float64{2}, []float64{3})
+	c.DrainUnordered(2)
+	c.MemTrim()
+	if cap(c.DataPoints) != 1 || c.LenDP() != 1 {
+		t.Errorf("got len %d cap %d, want 1 and 1", c.LenDP(), cap(c.DataPoints))
+	}
+}
+
+func TestCentroidDistributePayload(t *testing.T) {
+	src := newTestCentroid(t, []float64{0}, []float64{0.5}, []float64{9}, []float64{11})
+	near := newTestCentroid(t, []float64{10})
+	receivers := []PayloadReceiver{src, near}
+
+	src.DistributePayload(receivers, 3, 0)
+	if want := [][]float64{{0.5}}; !reflect.DeepEqual(dpVecs(src), want) {
+		t.Errorf("source kept %v, want %v", dpVecs(src), want)
+	}
+	if near.LenDP() != 2 {
+		t.Errorf("receiver got %d payloads, want 2", near.LenDP())
+	}
+}
+
+func TestCentroidDistributePayloadMinSim(t *testing.T) {
+	src := newTestCentroid(t, []float64{0}, []float64{1}, []float64{-1}, []float64{95})
+	far := newTestCentroid(t, []float64{100})
+
+	// The payload at 95 is within 5 of the receiver, a similarity of
+	// 1/6; the others are 99 and 101 away, far below the threshold.
+	src.DistributePayload([]PayloadReceiver{far}, 3, 0.1)
+	if want := [][]float64{{95}}; !reflect.DeepEqual(dpVecs(far), want) {
+		t.Errorf("receiver got %v, want %v", dpVecs(far), want)
+	}
+	if src.LenDP() != 2 {
+		t.Errorf("source kept %d payloads, want 2", src.LenDP())
+	}
+}
+
+func TestCentroidDistributeObserve(t *testing.T) {
+	src := newTestCentroid(t, []float64{0}, []float64{4}, []float64{50})
+	near := newTestCentroid(t, []float64{5})
+	mid := newTestCentroid(t, []float64{8})
+	far := newTestCentroid(t, []float64{20})
+
+	type choice struct {
+		vec       float64
+		receiver  int
+		score     float64
+		delivered bool
+	}
+	var got []choice
+	src.Distribute(DistributeArgs{
+		Receivers: []PayloadReceiver{far, mid, near},
+		N:         2,
+		MinScore:  0.1,
+		Observe: func(p payloadContainer, receiver int, score float64, delivered bool) {
+			got = append(got, choice{p.Vec()[0], receiver, score, delivered})
+		},
+	})
+	// 50 is 30 from the best receiver, a similarity of 1/31, below the
+	// acceptance score; 4 is 1 from near, a similarity of 1/2.
+	want := []choice{{50, 0, 1. / 31, false}, {4, 2, 0.5, true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("observed %v, want %v", got, want)
+	}
+	if want := [][]float64{{4}}; !reflect.DeepEqual(dpVecs(near), want) {
+		t.Errorf("best receiver got %v, want %v", dpVecs(near), want)
+	}
+	if mid.LenDP() != 0 || far.LenDP() != 0 {
+		t.Error("payload went to a worse receiver")
+	}
+	if want := [][]float64{{50}}; !reflect.DeepEqual(dpVecs(src), want) {
+		t.Errorf("source kept %v, want %v", dpVecs(src), want)
+	}
+}
+
+// sickReceiver is a Centroid reporting itself unhealthy.
+type sickReceiver struct{ *Centroid }
+
+func (sickReceiver) Healthy() bool { return false }
+
+func TestCentroidDistributePayloadSkipsUnhealthy(t *testing.T) {
+	src := newTestCentroid(t, []float64{0}, []float64{9})
+	sick := sickReceiver{newTestCentroid(t, []float64{10})}
+	farther := newTestCentroid(t, []float64{17})
+
+	src.DistributePayload([]PayloadReceiver{src, sick, farther}, 1, 0)
+	if sick.LenDP() != 0 {
+		t.Error("unhealthy receiver got a payload")
+	}
+	if farther.LenDP() != 1 {
+		t.Errorf("next best receiver got %d payloads, want 1", farther.LenDP())
+	}
+}
+
+func TestCentroidDrainOrderedFollowsMetric(t *testing.T) {
+	// Far along the centroid's direction, and close but off to the side.
+	far, aside := []float64{10, 0}, []float64{0.1, 0.3}
+	drainWorst := func(metric mathutils.Metric) []float64 {
+		c, ok := NewCentroid(NewCentroidArgs{InitVec: []float64{1, 0}, Metric: metric})
+		if !ok {
+			t.Fatal("failed to create centroid")
+		}
+		for _, v := range [][]float64{{1.1, 0}, far, aside} {
+			c.AddPayload(&testPayload{vec: v})
+		}
+		return c.DrainOrdered(1)[0].Vec()
+	}
+
+	if got := drainWorst(mathutils.EuclideanDistance); !reflect.DeepEqual(got, far) {
+		t.Errorf("euclidean: drained %v, want %v", got, far)
+	}
+	if got := drainWorst(mathutils.CosineDistance); !reflect.DeepEqual(got, aside) {
+		t.Errorf("cosine: drained %v, want %v", got, aside)
+	}
+	if _, ok := NewCentroid(NewCentroidArgs{InitVec: []float64{0}}); ok {
+		t.Error("created centroid without search funcs or metric")
+	}
+}
+
+func TestCentroidDrainOrderedTieBreak(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	// All but the last are equally far from the centroid at 0.
+	payloads := []*timedPayload{
+		{testPayload{vec: []float64{2}}, base.Add(2 * time.Hour)},
+		{testPayload{vec: []float64{-2}}, base},
+		{testPayload{vec: []float64{2}}, base.Add(time.Hour)},
+		{testPayload{vec: []float64{1}}, base.Add(-time.Hour)},
+	}
+
+	for _, tieBreak := range []bool{false, true} {
+		args := NewCentroidArgs{
+			InitVec:       []float64{0},
+			KNNSearchFunc: searchutils.KNNEuc,
+			KFNSearchFunc: searchutils.KFNEuc,
+			Metric:        mathutils.EuclideanDistance,
+		}
+		if tieBreak {
+			args.DrainTieBreak = OldestFirst
+		}
+		c, _ := NewCentroid(args)
+		for _, p := range payloads {
+			c.AddPayload(p)
+		}
+
+		drained := c.DrainOrdered(2)
+		want := []payloadContainer{payloads[0], payloads[1]}
+		if tieBreak {
+			want = []payloadContainer{payloads[1], payloads[2]}
+		}
+		if !reflect.DeepEqual(drained, want) {
+			t.Errorf("tiebreak=%v: drained %v, want %v", tieBreak, drained, want)
+		}
+		if c.LenDP() != 2 {
+			t.Errorf("tiebreak=%v: got LenDP %d, want 2", tieBreak, c.LenDP())
+		}
+	}
+}
+
+func TestCentroidDrainOrderedWithScores(t *testing.T) {
+	c := newTestCentroid(t, []float64{0, 0}, []float64{3, 4}, []float64{1, 0}, []float64{0, -6})
+	drained, scores := c.DrainOrderedWithScores(2)
+	if len(drained) != 2 || len(scores) != 2 {
+		t.Fatalf("got %d payloads and %d scores, want 2 and 2", len(drained), len(scores))
+	}
+
+	wantVecs := [][]float64{{0, -6}, {3, 4}}
+	wantScores := []float64{6, 5}
+	for i := range drained {
+		if !reflect.DeepEqual(drained[i].Vec(), wantVecs[i]) || scores[i] != wantScores[i] {
+			t.Errorf("%d: got %v scored %v, want %v scored %v",
+				i, drained[i].Vec(), scores[i], wantVecs[i], wantScores[i])
+		}
+	}
+}
+
+func TestCentroidReserve(t *testing.T) {
+	c := newTestCentroid(t, []float64{0}, []float64{1}, []float64{2})
+	c.Reserve(100)
+	if cap(c.DataPoints)-c.LenDP() < 100 {
+		t.Errorf("got spare capacity %d, want >= 100", cap(c.DataPoints)-c.LenDP())
+	}
+	if want := [][]float64{{1}, {2}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("got %v after reserve, want %v", dpVecs(c), want)
+	}
+}
+
+func TestCentroidGrowthHint(t *testing.T) {
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		GrowthHint:    50,
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+	})
+	c.AddPayload(&testPayload{vec: []float64{1}})
+	if cap(c.DataPoints) != 50 {
+		t.Errorf("got cap %d, want 50", cap(c.DataPoints))
+	}
+}
+
+func TestCentroidCompactRatio(t *testing.T) {
+	tests := []struct {
+		expired int
+		wantLen int
+	}{
+		{expired: 4, wantLen: 9}, // 4/8 doesn't exceed the ratio.
+		{expired: 5, wantLen: 4}, // 5/8 does: the 5 are compacted away.
+	}
+	for _, tt := range tests {
+		c, _ := NewCentroid(NewCentroidArgs{
+			InitVec:       []float64{0},
+			InitCap:       8,
+			CompactRatio:  0.5,
+			KNNSearchFunc: searchutils.KNNEuc,
+			KFNSearchFunc: searchutils.KFNEuc,
+			Metric:        mathutils.EuclideanDistance,
+		})
+		payloads := make([]*testPayload, 8)
+		for i := range payloads {
+			payloads[i] = &testPayload{vec: []float64{float64(i)}}
+			c.AddPayload(payloads[i])
+		}
+		for _, p := range payloads[:tt.expired] {
+			p.expired = true
+		}
+
+		c.AddPayload(&testPayload{vec: []float64{8}})
+		if c.LenDP() != tt.wantLen {
+			t.Errorf("%d expired: got LenDP %d, want %d", tt.expired, c.LenDP(), tt.wantLen)
+		}
+		if tt.wantLen < 8 && cap(c.DataPoints) >= 8 {
+			t.Errorf("%d expired: capacity %d not reduced", tt.expired, cap(c.DataPoints))
+		}
+	}
+}
+
+func TestCentroidCap(t *testing.T) {
+	c := newTestCentroid(t, []float64{0})
+	if c.Cap() != 0 {
+		t.Errorf("new: got cap %d, want 0", c.Cap())
+	}
+	for i := 0; i < 10; i++ {
+		c.AddPayload(&testPayload{vec: []float64{float64(i)}})
+	}
+	if c.Cap() < 10 {
+		t.Errorf("after growth: got cap %d, want at least 10", c.Cap())
+	}
+	grown := c.Cap()
+	c.DrainUnordered(6)
+	if c.Cap() != grown {
+		t.Errorf("after drain: got cap %d, want %d", c.Cap(), grown)
+	}
+	c.DataPoints[0].(*testPayload).expired = true
+	c.Compact()
+	if c.Cap() != 3 || c.LenDP() != 3 {
+		t.Errorf("after compact: got cap %d, len %d, want 3, 3", c.Cap(), c.LenDP())
+	}
+}
+
+func TestCentroidCompact(t *testing.T) {
+	c := newTestCentroid(t, []float64{0})
+	c.Reserve(10)
+	c.AddPayload(&testPayload{vec: []float64{1}})
+	c.AddPayload(&testPayload{vec: []float64{2}})
+	c.DataPoints[0].(*testPayload).expired = true
+
+	if got := c.Compact(); got != 1 {
+		t.Errorf("removed %d, want 1", got)
+	}
+	if want := [][]float64{{2}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("got %v, want %v", dpVecs(c), want)
+	}
+	if cap(c.DataPoints) != 1 {
+		t.Errorf("got cap %d, want 1", cap(c.DataPoints))
+	}
+}
+
+func benchmarkCentroidIngest(b *testing.B, reserve bool) {
+	const n = 10_000
+	payloads := make([]*testPayload, n)
+	for i := range payloads {
+		payloads[i] = &testPayload{vec: []float64{float64(i)}}
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c, _ := NewCentroid(NewCentroidArgs{
+			InitVec:       []float64{0},
+			KNNSearchFunc: searchutils.KNNEuc,
+			KFNSearchFunc: searchutils.KFNEuc,
+			Metric:        mathutils.EuclideanDistance,
+		})
+		if reserve {
+			c.Reserve(n)
+		}
+		for _, p := range payloads {
+			c.AddPayload(p)
+		}
+	}
+}
+
+func BenchmarkCentroidIngest(b *testing.B)        { benchmarkCentroidIngest(b, false) }
+func BenchmarkCentroidIngestReserve(b *testing.B) { benchmarkCentroidIngest(b, true) }
+
+func TestCentroidZeroVecFallback(t *testing.T) {
+	for _, fallback := range []bool{false, true} {
+		src, _ := NewCentroid(NewCentroidArgs{
+			InitVec:         []float64{1, 1},
+			KNNSearchFunc:   searchutils.KNNCos,
+			KFNSearchFunc:   searchutils.KFNCos,
+			Metric:          mathutils.CosineDistance,
+			ZeroVecFallback: fallback,
+		})
+		src.AddPayload(&testPayload{vec: []float64{1, 1}})
+		src.AddPayload(&testPayload{vec: []float64{0, 0}})
+		far := newTestCentroid(t, []float64{5, 5})
+		near := newTestCentroid(t, []float64{0.5, 0})
+
+		// The zero vector is the worst cosine fit, so it is the one drained.
+		src.DistributePayload([]PayloadReceiver{far, near}, 1, 0)
+
+		// Cosine scores every receiver 0 for the zero vector, so it lands
+		// in the first one unless Euclidean proximity is used.
+		want := far
+		if fallback {
+			want = near
+		}
+		if want.LenDP() != 1 || far.LenDP()+near.LenDP() != 1 {
+			t.Errorf("fallback=%v: far got %d, near got %d",
+				fallback, far.LenDP(), near.LenDP())
+		}
+	}
+}
+
+func TestCentroidZeroVecFallbackPayloads(t *testing.T) {
+	lookup := func(fallback bool) [][]float64 {
+		c, _ := NewCentroid(NewCentroidArgs{
+			InitVec:         []float64{1, 0},
+			Metric:          mathutils.CosineDistance,
+			ZeroVecFallback: fallback,
+		})
+		for _, v := range [][]float64{{0, 1}, {0, 0}, {10, 0}} {
+			c.AddPayload(&testPayload{vec: v})
+		}
+		return payloadVecs(c.KNNLookup([]float64{0.1, 0}, 3, false))
+	}
+
+	// Cosine scores the zero payload like the orthogonal one; by
+	// Euclidean distance it is only 0.1 from the target.
+	if want := [][]float64{{10, 0}, {0, 1}, {0, 0}}; !reflect.DeepEqual(lookup(false), want) {
+		t.Errorf("without fallback: got %v, want %v", lookup(false), want)
+	}
+	if want := [][]float64{{10, 0}, {0, 0}, {0, 1}}; !reflect.DeepEqual(lookup(true), want) {
+		t.Errorf("with fallback: got %v, want %v", lookup(true), want)
+	}
+}
+
+func TestCentroidInertia(t *testing.T) {
+	c := newTestCentroid(t, []float64{1, 1}, []float64{1, 1}, []float64{2, 3}, []float64{-1, 0}, []float64{9, 9})
+	c.DataPoints[3].(*testPayload).expired = true
+	// 0 + (1+4) + (4+1); the expired payload is skipped.
+	if got := c.Inertia(); got != 10 {
+		t.Errorf("got %v, want 10", got)
+	}
+
+	cosine, _ := NewCentroid(NewCentroidArgs{InitVec: []float64{1, 0}, Metric: mathutils.CosineDistance})
+	cosine.AddPayload(&testPayload{vec: []float64{3, 0}})
+	if got := cosine.Inertia(); got != 4 {
+		t.Errorf("cosine centroid: got %v, want 4", got)
+	}
+	if got := newTestCentroid(t, []float64{0}).Inertia(); got != 0 {
+		t.Errorf("empty: got %v, want 0", got)
+	}
+}
+
+func TestCentroidRadius(t *testing.T) {
+	c := newTestCentroid(t, []float64{0, 0})
+	if _, ok := c.Radius(); ok {
+		t.Error("empty: got a radius")
+	}
+	if _, ok := c.MeanDistance(); ok {
+		t.Error("empty: got a mean distance")
+	}
+
+	c.AddPayload(&testPayload{vec: []float64{3, 4}})
+	if got, ok := c.Radius(); !ok || got != 5 {
+		t.Errorf("single: got radius %v, %v, want 5", got, ok)
+	}
+	if got, ok := c.MeanDistance(); !ok || got != 5 {
+		t.Errorf("single: got mean distance %v, %v, want 5", got, ok)
+	}
+
+	c.AddPayload(&testPayload{vec: []float64{0, 1}})
+	c.AddPayload(&testPayload{vec: []float64{100, 0}})
+	c.DataPoints[2].(*testPayload).expired = true
+	if got, _ := c.Radius(); got != 5 {
+		t.Errorf("got radius %v, want 5", got)
+	}
+	if got, _ := c.MeanDistance(); got != 3 {
+		t.Errorf("got mean distance %v, want 3", got)
+	}
+	c.DataPoints[0].(*testPayload).expired = true
+	c.DataPoints[1].(*testPayload).expired = true
+	if _, ok := c.Radius(); ok {
+		t.Error("all expired: got a radius")
+	}
+}
+
+func TestCentroidRadiusApprox(t *testing.T) {
+	// Payloads at distances uniform in [0, 10) around the origin, in
+	// random directions: the mean distance is close to 5.
+	rng := rand.New(rand.NewSource(1))
+	c := newTestCentroid(t, []float64{0, 0})
+	var exact float64
+	for i := 0; i < 20_000; i++ {
+		r, angle := rng.Float64()*10, rng.Float64()*2*math.Pi
+		c.AddPayload(&testPayload{vec: []float64{r * math.Cos(angle), r * math.Sin(angle)}})
+		exact += math.Hypot(c.DataPoints[i].Vec()[0], c.DataPoints[i].Vec()[1])
+	}
+	exact /= float64(c.LenDP())
+	if got, _ := c.MeanDistance(); math.Abs(got-exact) > 1e-9 {
+		t.Fatalf("got mean distance %v, want %v", got, exact)
+	}
+
+	got, ok := c.RadiusApprox(1000, rand.New(rand.NewSource(2)))
+	if !ok || math.Abs(got-exact) > 0.05*exact {
+		t.Errorf("got %v, %v, want within 5%% of %v", got, ok, exact)
+	}
+	again, _ := c.RadiusApprox(1000, rand.New(rand.NewSource(2)))
+	if again != got {
+		t.Errorf("same seed gave %v, then %v", got, again)
+	}
+	if got, _ := c.RadiusApprox(c.LenDP(), nil); math.Abs(got-exact) > 1e-9 {
+		t.Errorf("full sample: got %v, want exactly %v", got, exact)
+	}
+
+	empty := newTestCentroid(t, []float64{0})
+	if _, ok := empty.RadiusApprox(10, nil); ok {
+		t.Error("estimated radius without payloads")
+	}
+	if _, ok := c.RadiusApprox(0, nil); ok {
+		t.Error("estimated radius from no samples")
+	}
+}
+
+func TestCentroidTrackedSSE(t *testing.T) {
+	c := newTestCentroid(t, []float64{0, 0}, []float64{1, 2}, []float64{-3, 1}, []float64{4, 4})
+	check := func(step string) {
+		t.Helper()
+		if got, want := c.TrackedSSE(), c.SSE(); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: tracked %v, fresh %v", step, got, want)
+		}
+	}
+	check("add")
+
+	c.MoveVector()
+	check("move")
+	c.AddPayload(&testPayload{vec: []float64{7, -2}})
+	c.AddPayload(&testPayload{vec: []float64{0.5, 0.5}})
+	check("add after move")
+	c.DrainOrdered(1)
+	check("drain ordered")
+	c.KNNLookup([]float64{1, 1}, 1, true)
+	check("drain lookup")
+	c.DrainUnordered(1)
+	check("drain unordered")
+	c.AddPayload(&testPayload{vec: []float64{2, 2}})
+	c.DataPoints[0].(*testPayload).expired = true
+	c.Expire()
+	check("expire")
+	c.MoveVector()
+	check("second move")
+	c.DrainUnordered(c.LenDP())
+	check("empty")
+}
+
+func benchmarkCentroidSSE(b *testing.B, tracked bool) {
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       make([]float64, 16),
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+	})
+	for i := 0; i < 10_000; i++ {
+		vec := make([]float64, 16)
+		vec[i%16] = float64(i)
+		c.AddPayload(&testPayload{vec: vec})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if tracked {
+			c.TrackedSSE()
+		} else {
+			c.SSE()
+		}
+	}
+}
+
+func BenchmarkCentroidSSE(b *testing.B)        { benchmarkCentroidSSE(b, false) }
+func BenchmarkCentroidTrackedSSE(b *testing.B) { benchmarkCentroidSSE(b, true) }
+
+func TestCentroidAutoNormalize(t *testing.T) {
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{1, 0},
+		KNNSearchFunc: searchutils.KNNCos,
+		KFNSearchFunc: searchutils.KFNCos,
+		Metric:        mathutils.CosineDistance,
+		AutoNormalize: true,
+	})
+	for _, v := range [][]float64{{3, 4}, {-2, 0}, {0.1, 0.1}} {
+		p := &testPayload{vec: v}
+		before := append([]float64(nil), v...)
+		if !c.AddPayload(p) {
+			t.Fatalf("rejected %v", v)
+		}
+		if !reflect.DeepEqual(v, before) {
+			t.Errorf("caller's vector changed from %v to %v", before, v)
+		}
+		stored := c.DataPoints[c.LenDP()-1].(*normalizedPayload)
+		if stored.Unwrap() != p {
+			t.Errorf("stored payload wraps %v, want %v", stored.Unwrap(), p)
+		}
+	}
+	if c.AddPayload(&testPayload{vec: []float64{0, 0}}) {
+		t.Error("accepted a zero vector")
+	}
+	for _, v := range dpVecs(c) {
+		if n := math.Hypot(v[0], v[1]); math.Abs(n-1) > 1e-12 {
+			t.Errorf("stored %v with norm %v", v, n)
+		}
+	}
+}
+
+func TestCentroidAutoNormalizeKeepsInterfaces(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	factory := &common.PayloadFactory{}
+	factory.Register("wire", func(data []byte) common.PayloadContainer {
+		return &wirePayload{testPayload{vec: []float64{0, 2}}, string(data)}
+	})
+	newCentroid := func() *Centroid {
+		c, _ := NewCentroid(NewCentroidArgs{
+			InitVec:        []float64{1, 0},
+			Metric:         mathutils.CosineDistance,
+			Clock:          func() time.Time { return now },
+			AutoNormalize:  true,
+			PayloadFactory: factory,
+		})
+		return c
+	}
+
+	c := newCentroid()
+	c.AddPayload(&timedPayload{testPayload{vec: []float64{3, 4}}, now.Add(-2 * time.Hour)})
+	c.AddPayload(&wirePayload{testPayload{vec: []float64{0, 2}}, "w"})
+	if got := c.AgeDistribution([]time.Duration{time.Hour}); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("got age distribution %v, want [0 1]", got)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := newCentroid()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dpVecs(decoded), [][]float64{{0.6, 0.8}, {0, 1}}) {
+		t.Errorf("decoded payloads %v, want unit vectors", dpVecs(decoded))
+	}
+	if p, ok := common.Unwrap(decoded.DataPoints[1]).(*wirePayload); !ok || p.name != "w" {
+		t.Errorf("got %#v, want the wire payload back", common.Unwrap(decoded.DataPoints[1]))
+	}
+	if n := decoded.ExpireOlderThan(time.Hour, now); n != 1 {
+		t.Errorf("expired %d payloads, want 1", n)
+	}
+	if n := c.ExpireOlderThan(time.Hour, now); n != 1 {
+		t.Errorf("expired %d payloads, want 1", n)
+	}
+}
+
+func TestCentroidID(t *testing.T) {
+	a := newTestCentroid(t, []float64{1, 2}, []float64{5, 5})
+	b := newTestCentroid(t, []float64{1, 2})
+	c := newTestCentroid(t, []float64{2, 1})
+	if a.ID() == "" || a.ID() != b.ID() {
+		t.Errorf("same initial vector gave IDs %q and %q", a.ID(), b.ID())
+	}
+	if a.ID() == c.ID() {
+		t.Errorf("different initial vectors share ID %q", a.ID())
+	}
+
+	id := a.ID()
+	a.MoveVector()
+	if a.ID() != id {
+		t.Errorf("ID changed from %q to %q after moving", id, a.ID())
+	}
+
+	named, _ := NewCentroid(NewCentroidArgs{
+		ID:            "shard-7",
+		InitVec:       []float64{1, 2},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+	})
+	if named.ID() != "shard-7" {
+		t.Errorf("got ID %q, want shard-7", named.ID())
+	}
+}
@@ -0,0 +1,76 @@
+package kmeans
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// fitState is the state of a fit between iterations, as written to
+// checkpoints (see FitArgs.Checkpoint). Fields are exported for gob.
+type fitState struct {
+	// Iter is the number of iterations run.
+	Iter int
+	// Centroids are the centroid vectors.
+	Centroids [][]float64
+	// Labels are the centroid indexes of the fitted vectors as of the
+	// last assignment, -1 for none.
+	Labels []int
+	// Changes is as returned by AssignmentChanges.
+	Changes []int
+	// Best and Stagnant track inertia for FitArgs.Patience.
+	Best     float64
+	Stagnant int
+
+	// The FitArgs that matter after seeding.
+	Patience       int
+	Tol            float64
+	BalanceFactor  float64
+	ChangeFraction float64
+}
+
+// ResumeFit continues a fit of vecs from the last complete checkpoint in r,
+// as written by Fit with FitArgs.Checkpoint, running until the fit
+// converges, stagnates, or maxIter iterations have run in total (counting
+// those before the checkpoint). Given the same vecs and maxIter as the
+// interrupted Fit, the model ends up as the Fit would have left it. A
+// checkpoint cut short by a crash is ignored in favour of the one before
+// it. The resumed fit writes no further checkpoints.
+//
+// Returns false, leaving the model untouched, if r holds no complete
+// checkpoint, if maxIter is below 1, or if vecs don't match the checkpoint
+// in number or dimension.
+func (km *KMeans) ResumeFit(r io.Reader, vecs [][]float64, maxIter int) bool {
+	st := lastCheckpoint(r)
+	if st == nil || maxIter < 1 || !sameDim(vecs) || len(vecs) != len(st.Labels) {
+		return false
+	}
+	if len(st.Centroids) == 0 || len(st.Centroids) > len(vecs) {
+		return false
+	}
+	for _, v := range st.Centroids {
+		if len(v) != len(vecs[0]) {
+			return false
+		}
+	}
+	for _, label := range st.Labels {
+		if label < -1 || label >= len(st.Centroids) {
+			return false
+		}
+	}
+	km.lloyd(vecs, st, maxIter, nil, 0)
+	return true
+}
+
+// lastCheckpoint returns the last fitState that decodes completely from r,
+// or nil if there is none.
+func lastCheckpoint(r io.Reader) *fitState {
+	dec := gob.NewDecoder(r)
+	var last *fitState
+	for {
+		var st fitState
+		if err := dec.Decode(&st); err != nil {
+			return last
+		}
+		last = &st
+	}
+}
@@ -0,0 +1,102 @@
+package kmeans
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// offsetWriter is a bytes.Buffer recording its length after every write.
+type offsetWriter struct {
+	bytes.Buffer
+	offsets []int
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.Buffer.Write(p)
+	w.offsets = append(w.offsets, w.Len())
+	return n, err
+}
+
+// sameFit reports whether a and b hold equal centroids and fit history.
+func sameFit(a, b *KMeans) bool {
+	if len(a.centroids) != len(b.centroids) || a.Iterations() != b.Iterations() {
+		return false
+	}
+	for i := range a.centroids {
+		if !a.centroids[i].Equal(b.centroids[i], 0) {
+			return false
+		}
+	}
+	return reflect.DeepEqual(a.AssignmentChanges(), b.AssignmentChanges())
+}
+
+func TestKMeansResumeFit(t *testing.T) {
+	vecs := uniformVecs(500, 1)
+	args := FitArgs{K: 5, MaxIter: 300, Patience: 100, Tol: 1e-12}
+	full := NewKMeans(NewKMeansArgs{})
+	if !full.Fit(vecs, args) {
+		t.Fatal("fit failed")
+	}
+	if full.Iterations() < 12 {
+		t.Fatalf("dataset converged too quickly (%d iterations) to test resuming", full.Iterations())
+	}
+
+	var w offsetWriter
+	args.Checkpoint, args.CheckpointEvery = &w, 4
+	checkpointed := NewKMeans(NewKMeansArgs{})
+	if !checkpointed.Fit(vecs, args) {
+		t.Fatal("checkpointed fit failed")
+	}
+	if !sameFit(checkpointed, full) {
+		t.Fatal("checkpointing changed the fit")
+	}
+	if len(w.offsets) < 3 {
+		t.Fatalf("got %d writes, want at least 3", len(w.offsets))
+	}
+
+	// Crash halfway through writing the last checkpoint.
+	last := len(w.offsets) - 1
+	cut := (w.offsets[last-1] + w.offsets[last]) / 2
+	resumed := NewKMeans(NewKMeansArgs{})
+	if !resumed.ResumeFit(bytes.NewReader(w.Bytes()[:cut]), vecs, args.MaxIter) {
+		t.Fatal("resume failed")
+	}
+	if !sameFit(resumed, full) {
+		t.Errorf("resumed fit ran %d iterations, changes %v; want %d, %v",
+			resumed.Iterations(), resumed.AssignmentChanges(),
+			full.Iterations(), full.AssignmentChanges())
+	}
+	if !reflect.DeepEqual(resumed.Assign(vecs), full.Assign(vecs)) {
+		t.Error("resumed fit assigns differently")
+	}
+}
+
+func TestKMeansResumeFitInvalid(t *testing.T) {
+	vecs := uniformVecs(50, 0)
+	var w bytes.Buffer
+	km := NewKMeans(NewKMeansArgs{})
+	km.Fit(vecs, FitArgs{K: 3, MaxIter: 300, Checkpoint: &w, CheckpointEvery: 1})
+	checkpoints := w.Bytes()
+	wide := make([][]float64, len(vecs))
+	for i, v := range vecs {
+		wide[i] = append([]float64{0}, v...)
+	}
+
+	tests := map[string]struct {
+		data    []byte
+		vecs    [][]float64
+		maxIter int
+	}{
+		"no checkpoint": {nil, vecs, 10},
+		"truncated":     {checkpoints[:10], vecs, 10},
+		"no iter":       {checkpoints, vecs, 0},
+		"fewer vecs":    {checkpoints, vecs[1:], 10},
+		"dim mismatch":  {checkpoints, wide, 10},
+	}
+	for name, test := range tests {
+		if km.ResumeFit(bytes.NewReader(test.data), test.vecs, test.maxIter) {
+			t.Errorf("%s: expected failure", name)
+		}
+	}
+}
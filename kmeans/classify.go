@@ -0,0 +1,50 @@
+package kmeans
+
+import (
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// KNNClassify predicts the label of query from the k training vectors
+// nearest to it under metric, where labels[i] is the label of train[i].
+// Each neighbour votes for its label, with weight 1, or with its similarity
+// 1/(1+d) to query if weighted is true, so that close neighbours outvote
+// far ones. On a tied vote the label with the nearest neighbour wins.
+//
+// Returns -1 if k is less than 1, metric is nil, train and labels differ in
+// length, or no training vector can be compared with query.
+func KNNClassify(
+	train [][]float64,
+	labels []int,
+	query []float64,
+	k int,
+	metric mathutils.Metric,
+	weighted bool,
+) int {
+	if k < 1 || metric == nil || len(train) != len(labels) {
+		return -1
+	}
+	neighbours := searchutils.KNNByMetric(metric)(query, mathutils.VecGenerator(train), k)
+
+	if len(neighbours) == 0 {
+		return -1
+	}
+
+	votes := make(map[int]float64)
+	for _, i := range neighbours {
+		vote := 1.
+		if weighted {
+			vote = similarity(metric, query, train[i])
+		}
+		votes[labels[i]] += vote
+	}
+	// Neighbours come nearest first, so taking the first label with the
+	// most votes breaks ties in favour of the nearer neighbour.
+	best := labels[neighbours[0]]
+	for _, i := range neighbours {
+		if votes[labels[i]] > votes[best] {
+			best = labels[i]
+		}
+	}
+	return best
+}
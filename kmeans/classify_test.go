@@ -0,0 +1,44 @@
+package kmeans
+
+import (
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+func TestKNNClassify(t *testing.T) {
+	// The query at 0 has one close neighbour labelled 2 and two far ones
+	// labelled 1.
+	train := [][]float64{{-5}, {0.1}, {5}, {40}}
+	labels := []int{1, 2, 1, 2}
+	euc := mathutils.EuclideanDistance
+	if got := KNNClassify(train, labels, []float64{0}, 3, euc, false); got != 1 {
+		t.Errorf("majority: got %d, want 1", got)
+	}
+	if got := KNNClassify(train, labels, []float64{0}, 3, euc, true); got != 2 {
+		t.Errorf("weighted: got %d, want 2", got)
+	}
+	// A tied vote goes to the label with the nearest neighbour.
+	if got := KNNClassify(train, labels, []float64{4}, 2, euc, false); got != 1 {
+		t.Errorf("tie: got %d, want 1", got)
+	}
+	if got := KNNClassify(train, []int{-7, 3, 3, 3}, []float64{-6}, 1, euc, false); got != -7 {
+		t.Errorf("negative label: got %d, want -7", got)
+	}
+}
+
+func TestKNNClassifyInvalid(t *testing.T) {
+	train := [][]float64{{0}, {1}}
+	euc := mathutils.EuclideanDistance
+	tests := map[string]int{
+		"k zero":         KNNClassify(train, []int{0, 1}, []float64{0}, 0, euc, false),
+		"nil metric":     KNNClassify(train, []int{0, 1}, []float64{0}, 1, nil, false),
+		"label mismatch": KNNClassify(train, []int{0}, []float64{0}, 1, euc, false),
+		"incomparable":   KNNClassify(train, []int{0, 1}, []float64{0, 0}, 1, euc, false),
+	}
+	for name, got := range tests {
+		if got != -1 {
+			t.Errorf("%s: got %d, want -1", name, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package kmeans
+
+import (
+	"math"
+	"math/rand"
+)
+
+// blobCenterBox bounds the coordinates of the centers MakeBlobs draws.
+const blobCenterBox = 10.
+
+// MakeBlobs generates nSamples vectors of dimension dim in nCenters
+// Gaussian blobs, along with the index of the blob each vector belongs to,
+// as ground truth for clustering. Centers are drawn uniformly from
+// [-10, 10] in every dimension and each blob is isotropic with standard
+// deviation spread. Samples are dealt to blobs in turn, so blob sizes
+// differ by at most one. Everything is drawn from rng, which defaults to a
+// fixed seed.
+//
+// Returns nil slices if nSamples, nCenters or dim is less than 1, or if
+// spread is negative.
+func MakeBlobs(nSamples, nCenters, dim int, spread float64, rng *rand.Rand) ([][]float64, []int) {
+	if nSamples < 1 || nCenters < 1 || dim < 1 || spread < 0 {
+		return nil, nil
+	}
+	if rng == nil {
+		rng = rand.New(rand.NewSource(0))
+	}
+
+	centers := make([][]float64, nCenters)
+	for i := range centers {
+		centers[i] = make([]float64, dim)
+		for j := range centers[i] {
+			centers[i][j] = (rng.Float64()*2 - 1) * blobCenterBox
+		}
+	}
+
+	vecs := make([][]float64, nSamples)
+	labels := make([]int, nSamples)
+	for i := range vecs {
+		labels[i] = i % nCenters
+		vecs[i] = make([]float64, dim)
+		for j, x := range centers[labels[i]] {
+			vecs[i][j] = x + rng.NormFloat64()*spread
+		}
+	}
+	return vecs, labels
+}
+
+// MakeMoons generates n 2D vectors on two interleaving half circles, along
+// with the half each belongs to: label 0 for the upper half of the unit
+// circle, and label 1 for a lower half circle shifted by (1, 0.5). Points
+// are evenly spaced along each half before Gaussian noise with standard
+// deviation noise is added to both coordinates. The halves aren't linearly
+// separable, which makes them a standard hard case for centroid-based
+// clustering. Noise is drawn from rng, which defaults to a fixed seed.
+//
+// Returns nil slices if n is less than 2 or noise is negative.
+func MakeMoons(n int, noise float64, rng *rand.Rand) ([][]float64, []int) {
+	if n < 2 || noise < 0 {
+		return nil, nil
+	}
+	nOuter := n / 2
+	return makeShapes(n, nOuter, noise, rng, func(label, i, size int) []float64 {
+		t := 0.
+		if size > 1 {
+			t = math.Pi * float64(i) / float64(size-1)
+		}
+		if label == 0 {
+			return []float64{math.Cos(t), math.Sin(t)}
+		}
+		return []float64{1 - math.Cos(t), 0.5 - math.Sin(t)}
+	})
+}
+
+// MakeCircles generates n 2D vectors on two concentric circles around the
+// origin, along with the circle each belongs to: label 0 for the unit
+// circle and label 1 for the inner circle of radius factor. Points are
+// evenly spaced around each circle before Gaussian noise with standard
+// deviation noise is added to both coordinates. Noise is drawn from rng,
+// which defaults to a fixed seed.
+//
+// Returns nil slices if n is less than 2, if factor is outside (0, 1), or
+// if noise is negative.
+func MakeCircles(n int, factor, noise float64, rng *rand.Rand) ([][]float64, []int) {
+	if n < 2 || factor <= 0 || factor >= 1 || noise < 0 {
+		return nil, nil
+	}
+	nOuter := n / 2
+	return makeShapes(n, nOuter, noise, rng, func(label, i, size int) []float64 {
+		t := 2 * math.Pi * float64(i) / float64(size)
+		r := 1.
+		if label == 1 {
+			r = factor
+		}
+		return []float64{r * math.Cos(t), r * math.Sin(t)}
+	})
+}
+
+// makeShapes is the shared part of MakeMoons and MakeCircles: it places the
+// first nFirst of n points on shape 0 and the rest on shape 1, where point
+// returns the i-th of size points on a shape, then adds noise.
+func makeShapes(
+	n, nFirst int,
+	noise float64,
+	rng *rand.Rand,
+	point func(label, i, size int) []float64,
+) ([][]float64, []int) {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(0))
+	}
+	vecs := make([][]float64, n)
+	labels := make([]int, n)
+	for i := range vecs {
+		label, index, size := 0, i, nFirst
+		if i >= nFirst {
+			label, index, size = 1, i-nFirst, n-nFirst
+		}
+		v := point(label, index, size)
+		for j := range v {
+			v[j] += rng.NormFloat64() * noise
+		}
+		vecs[i], labels[i] = v, label
+	}
+	return vecs, labels
+}
@@ -0,0 +1,160 @@
+package kmeans
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// adjustedRandIndex returns the adjusted Rand index of two labelings of
+// the same items: 1 for identical partitions, around 0 for independent
+// ones.
+func adjustedRandIndex(a, b []int) float64 {
+	type pair struct{ a, b int }
+	joint := map[pair]int{}
+	rows, cols := map[int]int{}, map[int]int{}
+	for i := range a {
+		joint[pair{a[i], b[i]}]++
+		rows[a[i]]++
+		cols[b[i]]++
+	}
+	choose2 := func(n int) float64 { return float64(n) * float64(n-1) / 2 }
+
+	var index, sumRows, sumCols float64
+	for _, n := range joint {
+		index += choose2(n)
+	}
+	for _, n := range rows {
+		sumRows += choose2(n)
+	}
+	for _, n := range cols {
+		sumCols += choose2(n)
+	}
+	expected := sumRows * sumCols / choose2(len(a))
+	maxIndex := (sumRows + sumCols) / 2
+	if maxIndex == expected {
+		return 1
+	}
+	return (index - expected) / (maxIndex - expected)
+}
+
+func TestAdjustedRandIndex(t *testing.T) {
+	if got := adjustedRandIndex([]int{0, 0, 1, 1}, []int{1, 1, 0, 0}); got != 1 {
+		t.Errorf("relabeled partition: got %v, want 1", got)
+	}
+	if got := adjustedRandIndex([]int{0, 0, 1, 1}, []int{0, 1, 0, 1}); got >= 0 {
+		t.Errorf("crossed partition: got %v, want < 0", got)
+	}
+}
+
+func TestMakeBlobs(t *testing.T) {
+	vecs, labels := MakeBlobs(301, 3, 4, 0.5, rand.New(rand.NewSource(1)))
+	if len(vecs) != 301 || len(labels) != 301 {
+		t.Fatalf("got %d vecs and %d labels, want 301", len(vecs), len(labels))
+	}
+	sizes := make([]int, 3)
+	for i, v := range vecs {
+		if len(v) != 4 {
+			t.Fatalf("vec %d has dimension %d, want 4", i, len(v))
+		}
+		sizes[labels[i]]++
+	}
+	if sizes[0] != 101 || sizes[1] != 100 || sizes[2] != 100 {
+		t.Errorf("got blob sizes %v", sizes)
+	}
+
+	km := NewKMeans(NewKMeansArgs{})
+	if !km.Fit(vecs, FitArgs{K: 3, MaxIter: 100, Rng: rand.New(rand.NewSource(1))}) {
+		t.Fatal("fit failed")
+	}
+	if ari := adjustedRandIndex(km.Assign(vecs), labels); ari < 0.99 {
+		t.Errorf("got ARI %v against ground truth, want >= 0.99", ari)
+	}
+}
+
+func TestMakeBlobsInvalid(t *testing.T) {
+	for _, args := range [][4]int{{0, 1, 1, 1}, {1, 0, 1, 1}, {1, 1, 0, 1}, {1, 1, 1, -1}} {
+		vecs, labels := MakeBlobs(args[0], args[1], args[2], float64(args[3]), nil)
+		if vecs != nil || labels != nil {
+			t.Errorf("%v: expected nil result", args)
+		}
+	}
+}
+
+// labelCounts returns how many of labels are 0 and 1.
+func labelCounts(labels []int) [2]int {
+	var counts [2]int
+	for _, l := range labels {
+		counts[l]++
+	}
+	return counts
+}
+
+func TestMakeMoons(t *testing.T) {
+	const eps = 1e-9
+	vecs, labels := MakeMoons(51, 0, nil)
+	if len(vecs) != 51 || labelCounts(labels) != [2]int{25, 26} {
+		t.Fatalf("got %d vecs with label counts %v", len(vecs), labelCounts(labels))
+	}
+	for i, v := range vecs {
+		// Each moon is a half circle of radius 1 around its own center,
+		// opening towards the other.
+		center, above := []float64{0, 0}, true
+		if labels[i] == 1 {
+			center, above = []float64{1, 0.5}, false
+		}
+		if r := math.Hypot(v[0]-center[0], v[1]-center[1]); math.Abs(r-1) > eps {
+			t.Errorf("vec %d (%v): radius %v around %v, want 1", i, v, r, center)
+		}
+		if dy := v[1] - center[1]; above && dy < -eps || !above && dy > eps {
+			t.Errorf("vec %d (%v): on the wrong side of %v", i, v, center)
+		}
+	}
+
+	noisy, _ := MakeMoons(51, 0.1, rand.New(rand.NewSource(1)))
+	if reflect.DeepEqual(noisy, vecs) {
+		t.Error("noise had no effect")
+	}
+}
+
+func TestMakeCircles(t *testing.T) {
+	vecs, labels := MakeCircles(40, 0.5, 0, nil)
+	if len(vecs) != 40 || labelCounts(labels) != [2]int{20, 20} {
+		t.Fatalf("got %d vecs with label counts %v", len(vecs), labelCounts(labels))
+	}
+	for i, v := range vecs {
+		want := 1.
+		if labels[i] == 1 {
+			want = 0.5
+		}
+		if r := math.Hypot(v[0], v[1]); math.Abs(r-want) > 1e-9 {
+			t.Errorf("vec %d (%v): radius %v, want %v", i, v, r, want)
+		}
+	}
+
+	// Both circles share a mean, so centroid-based clustering can't
+	// separate them.
+	km := NewKMeans(NewKMeansArgs{})
+	km.Fit(vecs, FitArgs{K: 2, MaxIter: 100})
+	if ari := adjustedRandIndex(km.Assign(vecs), labels); ari > 0.5 {
+		t.Errorf("k-means separated the circles: ARI %v", ari)
+	}
+}
+
+func TestMakeShapesInvalid(t *testing.T) {
+	if vecs, _ := MakeMoons(1, 0, nil); vecs != nil {
+		t.Error("MakeMoons: accepted n=1")
+	}
+	if vecs, _ := MakeMoons(10, -1, nil); vecs != nil {
+		t.Error("MakeMoons: accepted negative noise")
+	}
+	for _, factor := range []float64{0, 1} {
+		if vecs, _ := MakeCircles(10, factor, 0, nil); vecs != nil {
+			t.Errorf("MakeCircles: accepted factor %v", factor)
+		}
+	}
+	if vecs, _ := MakeCircles(10, 0.5, -1, nil); vecs != nil {
+		t.Error("MakeCircles: accepted negative noise")
+	}
+}
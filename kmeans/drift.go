@@ -0,0 +1,109 @@
+package kmeans
+
+import (
+	"math"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// DriftMonitor watches a stream of vectors for concept drift against a
+// fitted model. It tracks the distance from each vector to its nearest
+// centroid: the first Warmup distances form a baseline, and each following
+// window of Window distances is compared with it. A window whose mean
+// distance is more than Threshold standard errors away from the baseline
+// mean signals drift, a sign the model no longer fits the data and should
+// be refit.
+//
+// The model is only read, but must not be modified concurrently.
+type DriftMonitor struct {
+	model     *KMeans
+	warmup    int
+	window    int
+	threshold float64
+	onDrift   func(score float64)
+
+	baseline mathutils.RunningVariance
+	current  mathutils.RunningVariance
+}
+
+// NewDriftMonitorArgs is the argument set for NewDriftMonitor.
+type NewDriftMonitorArgs struct {
+	// Model is the fitted model vectors are assigned with. Required.
+	Model *KMeans
+	// Warmup is the number of distances forming the baseline; at least 2.
+	Warmup int
+	// Window is the number of distances compared with the baseline at a
+	// time; at least 1.
+	Window int
+	// Threshold is the drift score, in standard errors, above which a
+	// window signals drift. Must be positive; 3 to 5 are typical.
+	Threshold float64
+	// OnDrift, if set, is called with the drift score of every window
+	// that signals drift.
+	OnDrift func(score float64)
+}
+
+// NewDriftMonitor creates a DriftMonitor from args. Returns false if the
+// model is nil or any other argument is out of range.
+func NewDriftMonitor(args NewDriftMonitorArgs) (*DriftMonitor, bool) {
+	if args.Model == nil || args.Warmup < 2 || args.Window < 1 || !(args.Threshold > 0) {
+		return nil, false
+	}
+	return &DriftMonitor{
+		model:     args.Model,
+		warmup:    args.Warmup,
+		window:    args.Window,
+		threshold: args.Threshold,
+		onDrift:   args.OnDrift,
+	}, true
+}
+
+// Observe records the distance from vec to its nearest centroid. It returns
+// true, after calling OnDrift, if vec completes a window that signals
+// drift. Vectors that can't be compared with the centroids are ignored.
+func (m *DriftMonitor) Observe(vec []float64) bool {
+	i := nearestCentroid(m.model.centroids, vec, m.model.metric)
+	if i == -1 {
+		return false
+	}
+	d, _ := m.model.metric(m.model.centroids[i].vec, vec)
+
+	if m.baseline.Count() < m.warmup {
+		m.baseline.Add(d)
+		return false
+	}
+	m.current.Add(d)
+	if m.current.Count() < m.window {
+		return false
+	}
+	score := m.score()
+	m.current.Reset()
+	if score <= m.threshold {
+		return false
+	}
+	if m.onDrift != nil {
+		m.onDrift(score)
+	}
+	return true
+}
+
+// score returns how many standard errors the current window's mean distance
+// lies from the baseline mean. With a baseline of identical distances, any
+// difference scores infinitely high.
+func (m *DriftMonitor) score() float64 {
+	diff := math.Abs(m.current.Mean() - m.baseline.Mean())
+	se := m.baseline.StdDev() / math.Sqrt(float64(m.current.Count()))
+	if se == 0 {
+		if diff == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return diff / se
+}
+
+// Ready reports whether the baseline is complete, so that windows are
+// being compared with it.
+func (m *DriftMonitor) Ready() bool {
+	return m.baseline.Count() >= m.warmup
+}
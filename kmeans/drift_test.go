@@ -0,0 +1,62 @@
+package kmeans
+
+import "testing"
+
+func TestDriftMonitor(t *testing.T) {
+	centers := [][]float64{{0, 0}, {20, 0}}
+	vecs := blobVecs(centers, 300, 1, 1)
+	km := NewKMeans(NewKMeansArgs{})
+	if !km.Fit(vecs, FitArgs{K: 2, MaxIter: 100, Seed: SeedKMeansPP}) {
+		t.Fatal("fit failed")
+	}
+
+	var scores []float64
+	m, ok := NewDriftMonitor(NewDriftMonitorArgs{
+		Model:     km,
+		Warmup:    200,
+		Window:    50,
+		Threshold: 5,
+		OnDrift:   func(score float64) { scores = append(scores, score) },
+	})
+	if !ok {
+		t.Fatal("failed to create monitor")
+	}
+
+	// Same distribution as the fit: no drift.
+	for i, v := range blobVecs(centers, 600, 1, 2) {
+		if m.Observe(v) {
+			t.Fatalf("drift signalled at stable vector %d", i)
+		}
+	}
+	if !m.Ready() {
+		t.Fatal("baseline incomplete after warmup")
+	}
+
+	// The data moves away from the centroids: drift within a window.
+	shifted := blobVecs([][]float64{{0, 5}, {20, 5}}, 25, 1, 3)
+	fired := false
+	for _, v := range shifted {
+		fired = m.Observe(v) || fired
+	}
+	if !fired || len(scores) != 1 || scores[0] <= 5 {
+		t.Errorf("fired: %v, scores: %v; want one score above 5", fired, scores)
+	}
+}
+
+func TestNewDriftMonitorInvalid(t *testing.T) {
+	km := NewKMeans(NewKMeansArgs{})
+	valid := NewDriftMonitorArgs{Model: km, Warmup: 2, Window: 1, Threshold: 1}
+	tests := map[string]func(a *NewDriftMonitorArgs){
+		"nil model":      func(a *NewDriftMonitorArgs) { a.Model = nil },
+		"short warmup":   func(a *NewDriftMonitorArgs) { a.Warmup = 1 },
+		"empty window":   func(a *NewDriftMonitorArgs) { a.Window = 0 },
+		"zero threshold": func(a *NewDriftMonitorArgs) { a.Threshold = 0 },
+	}
+	for name, mutate := range tests {
+		args := valid
+		mutate(&args)
+		if _, ok := NewDriftMonitor(args); ok {
+			t.Errorf("%s: expected failure", name)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package kmeans
+
+import "github.com/crunchypi/net-means/mathutils"
+
+// Ensemble combines several fitted models, e.g. fits of the same data from
+// different seeds, into consensus clustering. The models' label spaces are
+// unrelated, so they are reconciled through the co-association of a
+// reference set of vectors: how often each pair of them is clustered
+// together. The consensus labels are the clusters of that co-association,
+// and every cluster of every model votes for the consensus labels of its
+// reference members. Labels from the consensus are more stable than those
+// of any single model, which may have landed in a poor local optimum.
+type Ensemble struct {
+	models []*KMeans
+	// votes[m][c][l] is the fraction of the reference vectors in cluster c
+	// of model m that have consensus label l.
+	votes [][][]float64
+	k     int
+}
+
+// NewEnsembleArgs is the argument set for NewEnsemble.
+type NewEnsembleArgs struct {
+	// Models are the fitted models to combine; at least one.
+	Models []*KMeans
+	// Vecs is the reference set the consensus is built from, typically
+	// the data the models were fitted on. Every model must be able to
+	// assign every vector. Co-association takes memory and time
+	// quadratic in its size, so subsample large datasets.
+	Vecs [][]float64
+	// K is the number of consensus labels, in [1, len(Vecs)].
+	K int
+	// Linkage is how the co-association is clustered; see Agglomerative.
+	// Defaults to LinkageAverage.
+	Linkage string
+}
+
+// NewEnsemble builds the consensus of args.Models over args.Vecs. Returns
+// false if there are no models, if any of them is nil or can't assign all
+// of Vecs, if K is out of range, or if the linkage is unknown.
+func NewEnsemble(args NewEnsembleArgs) (*Ensemble, bool) {
+	if len(args.Models) == 0 || args.K < 1 || args.K > len(args.Vecs) {
+		return nil, false
+	}
+	if args.Linkage == "" {
+		args.Linkage = LinkageAverage
+	}
+	update, ok := linkageUpdates[args.Linkage]
+	if !ok {
+		return nil, false
+	}
+
+	labels := make([][]int, len(args.Models))
+	for m, model := range args.Models {
+		if model == nil {
+			return nil, false
+		}
+		labels[m] = model.Assign(args.Vecs)
+		for _, label := range labels[m] {
+			if label == -1 {
+				return nil, false
+			}
+		}
+	}
+
+	// Distance is the fraction of models separating a pair, compared
+	// through the labels each vector got from every model.
+	n := len(args.Vecs)
+	assigned := make([][]float64, n)
+	for i := range assigned {
+		assigned[i] = make([]float64, len(labels))
+		for m := range labels {
+			assigned[i][m] = float64(labels[m][i])
+		}
+	}
+	dist, err := mathutils.DistanceMatrix(assigned, separation)
+	if err != nil {
+		return nil, false
+	}
+	dendrogram := &Dendrogram{n: n, Merges: mergeClosest(dist, args.K, update)}
+	consensus := dendrogram.CutAt(args.K)
+
+	votes := make([][][]float64, len(args.Models))
+	for m, model := range args.Models {
+		votes[m] = make([][]float64, len(model.centroids))
+		sizes := make([]int, len(model.centroids))
+		for c := range votes[m] {
+			votes[m][c] = make([]float64, args.K)
+		}
+		for i, c := range labels[m] {
+			votes[m][c][consensus[i]]++
+			sizes[c]++
+		}
+		for c, size := range sizes {
+			for l := range votes[m][c] {
+				if size > 0 {
+					votes[m][c][l] /= float64(size)
+				}
+			}
+		}
+	}
+
+	return &Ensemble{models: args.Models, votes: votes, k: args.K}, true
+}
+
+// Predict returns the consensus label of vec, in [0, K): the label with
+// the most votes from the clusters the models assign vec to, lowest label
+// on ties. Returns -1 if no model can assign vec.
+func (e *Ensemble) Predict(vec []float64) int {
+	scores := make([]float64, e.k)
+	voted := false
+	for m, model := range e.models {
+		c := nearestCentroid(model.centroids, vec, model.metric)
+		if c == -1 {
+			continue
+		}
+		voted = true
+		for l, v := range e.votes[m][c] {
+			scores[l] += v
+		}
+	}
+	if !voted {
+		return -1
+	}
+	best := 0
+	for l, s := range scores {
+		if s > scores[best] {
+			best = l
+		}
+	}
+	return best
+}
+
+// separation is the fraction of elements in which v1 and v2 differ. For the
+// label vectors of NewEnsemble, that is the fraction of models that put the
+// two labelled vectors in different clusters.
+func separation(v1, v2 []float64) (float64, error) {
+	apart := 0
+	for i := range v1 {
+		if v1[i] != v2[i] {
+			apart++
+		}
+	}
+	return float64(apart) / float64(len(v1)), nil
+}
@@ -0,0 +1,62 @@
+package kmeans
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestEnsemble(t *testing.T) {
+	vecs, truth := MakeBlobs(120, 4, 2, 1, rand.New(rand.NewSource(1)))
+
+	// Single-iteration fits from random seeds: each lands somewhere
+	// different, and most split or merge some blobs.
+	var models []*KMeans
+	var meanARI float64
+	for seed := int64(0); seed < 10; seed++ {
+		km := NewKMeans(NewKMeansArgs{})
+		km.Fit(vecs, FitArgs{K: 4, MaxIter: 1, Rng: rand.New(rand.NewSource(seed))})
+		models = append(models, km)
+		meanARI += adjustedRandIndex(km.Assign(vecs), truth) / 10
+	}
+
+	e, ok := NewEnsemble(NewEnsembleArgs{Models: models, Vecs: vecs, K: 4})
+	if !ok {
+		t.Fatal("failed to create ensemble")
+	}
+	labels := make([]int, len(vecs))
+	for i, v := range vecs {
+		labels[i] = e.Predict(v)
+	}
+	ari := adjustedRandIndex(labels, truth)
+	t.Logf("ensemble ARI %.3f, mean model ARI %.3f", ari, meanARI)
+	if ari <= meanARI {
+		t.Errorf("ensemble ARI %.3f not above mean model ARI %.3f", ari, meanARI)
+	}
+
+	if got := e.Predict([]float64{1, 2, 3}); got != -1 {
+		t.Errorf("incomparable vector: got %d, want -1", got)
+	}
+}
+
+func TestNewEnsembleInvalid(t *testing.T) {
+	km := fitTestModel(t, twoGroups, 2)
+	valid := NewEnsembleArgs{Models: []*KMeans{km}, Vecs: twoGroups, K: 2}
+	if _, ok := NewEnsemble(valid); !ok {
+		t.Fatal("valid args rejected")
+	}
+	tests := map[string]func(a *NewEnsembleArgs){
+		"no models":    func(a *NewEnsembleArgs) { a.Models = nil },
+		"nil model":    func(a *NewEnsembleArgs) { a.Models = []*KMeans{km, nil} },
+		"zero k":       func(a *NewEnsembleArgs) { a.K = 0 },
+		"k above n":    func(a *NewEnsembleArgs) { a.K = len(twoGroups) + 1 },
+		"bad linkage":  func(a *NewEnsembleArgs) { a.Linkage = "ward" },
+		"unassignable": func(a *NewEnsembleArgs) { a.Vecs = [][]float64{{1}, {2}} },
+	}
+	for name, mutate := range tests {
+		args := valid
+		mutate(&args)
+		if _, ok := NewEnsemble(args); ok {
+			t.Errorf("%s: expected failure", name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package kmeans
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ExportClusters writes the vectors of each cluster's non-expired payloads
+// to the writer w returns for that cluster, as newline-delimited JSON
+// arrays, e.g. to store clusters in partitioned files. w is called once per
+// centroid, with its index as in Iterate, including for empty clusters;
+// clusters it returns a nil writer for are skipped. Export stops at the
+// first write error, which is returned with the cluster index.
+func (km *KMeans) ExportClusters(w func(cluster int) io.Writer) error {
+	for i, c := range km.centroids {
+		out := w(i)
+		if out == nil {
+			continue
+		}
+		enc := json.NewEncoder(out)
+		for _, p := range c.DataPoints {
+			if p.Expired() {
+				continue
+			}
+			if err := enc.Encode(p.Vec()); err != nil {
+				return fmt.Errorf("cluster %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
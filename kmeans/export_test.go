@@ -0,0 +1,66 @@
+package kmeans
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestKMeansExportClusters(t *testing.T) {
+	km := fitTestModel(t, twoGroups, 2)
+	expired := &testPayload{vec: []float64{5, 5}}
+	km.centroids[1].AddPayload(expired)
+	expired.expired = true
+
+	bufs := map[int]*bytes.Buffer{}
+	err := km.ExportClusters(func(cluster int) io.Writer {
+		bufs[cluster] = &bytes.Buffer{}
+		return bufs[cluster]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bufs) != 2 {
+		t.Fatalf("got writers for %d clusters, want 2", len(bufs))
+	}
+	for i, c := range km.centroids {
+		var got [][]float64
+		scanner := bufio.NewScanner(bufs[i])
+		for scanner.Scan() {
+			var vec []float64
+			if err := json.Unmarshal(scanner.Bytes(), &vec); err != nil {
+				t.Fatalf("cluster %d: bad line %q", i, scanner.Text())
+			}
+			got = append(got, vec)
+		}
+		var want [][]float64
+		for _, p := range c.DataPoints {
+			if !p.Expired() {
+				want = append(want, p.Vec())
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cluster %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	err = km.ExportClusters(func(cluster int) io.Writer {
+		if cluster == 0 {
+			return nil
+		}
+		return failingWriter{}
+	})
+	if err == nil || !strings.Contains(err.Error(), "cluster 1") {
+		t.Errorf("got err %v, want a write error for cluster 1", err)
+	}
+}
@@ -0,0 +1,560 @@
+package kmeans
+
+import (
+	"encoding/gob"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// defaultMaxIter is the iteration cap for fits that don't take one.
+const defaultMaxIter = 300
+
+// vecPayload is the payload Fit wraps raw vectors in. It never expires.
+type vecPayload struct {
+	vec []float64
+}
+
+func (p *vecPayload) Vec() []float64 { return p.vec }
+func (p *vecPayload) Expired() bool  { return false }
+
+// KMeans is a k-means model: a set of centroids fitted to data, each holding
+// the payloads assigned to it.
+type KMeans struct {
+	centroids  []*Centroid
+	iterations int
+	changes    []int
+
+	metric        mathutils.Metric
+	knnSearchFunc knnSearchFunc
+	kfnSearchFunc knnSearchFunc
+	temperature   float64
+}
+
+// NewKMeansArgs is the argument set for NewKMeans. All fields are optional.
+type NewKMeansArgs struct {
+	// Metric assigns vectors to their nearest centroid. It is also the
+	// Metric of the centroids the model creates. Defaults to
+	// mathutils.EuclideanDistance.
+	Metric mathutils.Metric
+	// KNNSearchFunc configures the centroids the model creates. Defaults
+	// to ranking by Metric if set, or to searchutils.KNNEuc.
+	KNNSearchFunc knnSearchFunc
+	// KFNSearchFunc configures the centroids the model creates. Defaults
+	// to ranking by Metric if set, or to searchutils.KFNEuc.
+	KFNSearchFunc knnSearchFunc
+	// Temperature scales distances in PredictProba. Lower temperatures
+	// make assignments harder, higher ones softer. Defaults to 1;
+	// negative values are treated as the default.
+	Temperature float64
+}
+
+// NewKMeans creates an empty model configured by args. Use Fit to populate
+// it.
+func NewKMeans(args NewKMeansArgs) *KMeans {
+	if args.Metric == nil {
+		args.Metric = mathutils.EuclideanDistance
+		if args.KNNSearchFunc == nil {
+			args.KNNSearchFunc = searchutils.KNNEuc
+		}
+		if args.KFNSearchFunc == nil {
+			args.KFNSearchFunc = searchutils.KFNEuc
+		}
+	}
+	if args.KNNSearchFunc == nil {
+		args.KNNSearchFunc = searchutils.KNNByMetric(args.Metric)
+	}
+	if args.KFNSearchFunc == nil {
+		args.KFNSearchFunc = searchutils.KFNByMetric(args.Metric)
+	}
+	if args.Temperature <= 0 {
+		args.Temperature = 1
+	}
+	return &KMeans{
+		metric:        args.Metric,
+		knnSearchFunc: args.KNNSearchFunc,
+		kfnSearchFunc: args.KFNSearchFunc,
+		temperature:   args.Temperature,
+	}
+}
+
+// newCentroid creates an empty centroid at vec configured by the model.
+func (km *KMeans) newCentroid(vec []float64) *Centroid {
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       vec,
+		KNNSearchFunc: km.knnSearchFunc,
+		KFNSearchFunc: km.kfnSearchFunc,
+		Metric:        km.metric,
+	})
+	return c
+}
+
+// FitArgs is the argument set for KMeans.Fit.
+type FitArgs struct {
+	// K is the number of clusters, in [1, len(vecs)].
+	K int
+	// MaxIter caps the number of assign-and-move iterations; at least 1.
+	MaxIter int
+	// Rng drives seeding. Defaults to a fixed seed, so fits are
+	// reproducible unless told otherwise.
+	Rng *rand.Rand
+	// Seed picks the initial centroid vectors, e.g. SeedKMeansPP.
+	// Defaults to SeedRandom.
+	Seed SeedFunc
+	// Patience, if positive, stops the fit early once inertia has gone
+	// Patience consecutive iterations without improving on its best value
+	// by more than Tol. This catches runs whose assignments keep shuffling
+	// without settling.
+	Patience int
+	// Tol is the minimum inertia improvement that resets Patience.
+	Tol float64
+	// BalanceFactor, if positive, discourages uneven cluster sizes: the
+	// cost of assigning a vector to a centroid becomes its distance plus
+	// BalanceFactor times the centroid's current size relative to the
+	// ideal size len(vecs)/K. Useful when clusters double as shards.
+	// Only the memberships built by Fit are affected, not Assign.
+	BalanceFactor float64
+	// ChangeFraction, if positive, loosens convergence: the fit stops once
+	// fewer than this fraction of vecs change cluster in an iteration,
+	// rather than only once none do. High-dimensional or noisy data often
+	// keeps a few vectors flipping between neighbouring centroids
+	// indefinitely. In [0, 1).
+	ChangeFraction float64
+	// Checkpoint, if set along with CheckpointEvery, receives the state of
+	// the fit every CheckpointEvery iterations, so that a long fit can be
+	// continued with ResumeFit after a crash instead of starting over.
+	// Checkpoints are gob-encoded and appended to the same stream, e.g. a
+	// file; ResumeFit continues from the last complete one. Write errors
+	// don't fail the fit, they only lose checkpoints.
+	Checkpoint io.Writer
+	// CheckpointEvery is the number of iterations between checkpoints; not
+	// negative.
+	CheckpointEvery int
+}
+
+// Fit clusters vecs with Lloyd's algorithm: K initial centroids are picked
+// by the SeedFunc (at random by default), then vectors are repeatedly
+// assigned to their nearest centroid and every centroid is moved to the
+// mean of its members, until assignments stop changing (or nearly so, with
+// ChangeFraction), MaxIter iterations have run, or (with Patience) inertia
+// stagnates. Any previous state of the model is replaced, and each centroid
+// ends up holding its member vectors as payloads.
+//
+// Returns false, leaving the model untouched, if K, MaxIter, ChangeFraction
+// or CheckpointEvery is out of range, if vecs holds nil vectors or vectors
+// of differing dimension, or if the SeedFunc doesn't return K vectors of
+// that dimension.
+func (km *KMeans) Fit(vecs [][]float64, args FitArgs) bool {
+	if args.K < 1 || args.K > len(vecs) || args.MaxIter < 1 || !sameDim(vecs) {
+		return false
+	}
+	if args.ChangeFraction < 0 || args.ChangeFraction >= 1 || args.CheckpointEvery < 0 {
+		return false
+	}
+	rng := args.Rng
+	if rng == nil {
+		rng = rand.New(rand.NewSource(0))
+	}
+	seed := args.Seed
+	if seed == nil {
+		seed = SeedRandom
+	}
+
+	seeds := seed(vecs, args.K, km.metric, rng)
+	if len(seeds) != args.K {
+		return false
+	}
+	for _, v := range seeds {
+		if v == nil || len(v) != len(vecs[0]) {
+			return false
+		}
+	}
+	labels := make([]int, len(vecs))
+	for i := range labels {
+		labels[i] = -1
+	}
+	st := &fitState{
+		Centroids:      seeds,
+		Labels:         labels,
+		Best:           math.Inf(1),
+		Patience:       args.Patience,
+		Tol:            args.Tol,
+		BalanceFactor:  args.BalanceFactor,
+		ChangeFraction: args.ChangeFraction,
+	}
+	var enc *gob.Encoder
+	if args.Checkpoint != nil && args.CheckpointEvery > 0 {
+		enc = gob.NewEncoder(args.Checkpoint)
+	}
+	km.lloyd(vecs, st, args.MaxIter, enc, args.CheckpointEvery)
+	return true
+}
+
+// lloyd runs Lloyd iterations over vecs from st until assignments converge,
+// inertia stagnates or st.Iter reaches maxIter, and installs the result in
+// the model. If enc is non-nil, st is written to it every `every`
+// iterations; write errors are ignored.
+func (km *KMeans) lloyd(vecs [][]float64, st *fitState, maxIter int, enc *gob.Encoder, every int) {
+	centroids := make([]*Centroid, len(st.Centroids))
+	for i, v := range st.Centroids {
+		centroids[i] = km.newCentroid(v)
+	}
+	payloads := make([]payloadContainer, len(vecs))
+	for i, v := range vecs {
+		payloads[i] = &vecPayload{vec: v}
+	}
+
+	converged := false
+	for st.Iter < maxIter {
+		st.Iter++
+		changed := assign(centroids, payloads, st.Labels, km.metric, st.BalanceFactor)
+		st.Changes = append(st.Changes, changed)
+		if changed == 0 || float64(changed) < st.ChangeFraction*float64(len(vecs)) {
+			converged = true
+			break
+		}
+		for _, c := range centroids {
+			c.MoveVector()
+		}
+
+		if st.Patience > 0 {
+			if current := inertia(centroids); st.Iter == 1 || st.Best-current > st.Tol {
+				st.Best, st.Stagnant = current, 0
+			} else if st.Stagnant++; st.Stagnant >= st.Patience {
+				break
+			}
+		}
+		if enc != nil && st.Iter%every == 0 {
+			for i, c := range centroids {
+				st.Centroids[i] = c.VecCopy()
+			}
+			enc.Encode(st)
+		}
+	}
+	if !converged {
+		// Make memberships reflect the final centroid vectors.
+		assign(centroids, payloads, st.Labels, km.metric, st.BalanceFactor)
+	}
+
+	km.centroids = centroids
+	km.iterations = st.Iter
+	km.changes = st.Changes
+}
+
+// Iterations returns the number of iterations the last successful Fit ran.
+func (km *KMeans) Iterations() int {
+	return km.iterations
+}
+
+// AssignmentChanges returns, for each iteration the last successful Fit
+// ran, how many vectors changed cluster. The first iteration counts every
+// vector; on a converging fit the counts dwindle and the last is 0. Useful
+// for diagnosing fits that don't converge.
+func (km *KMeans) AssignmentChanges() []int {
+	return append([]int(nil), km.changes...)
+}
+
+// FitSubsample fits the model on a random subsample of sampleSize vectors
+// from vecs and then assigns all of vecs to the resulting centroids,
+// without moving them again. This trades a little accuracy for much faster
+// fits on huge datasets. The subsample and the seeding are both drawn from
+// rng, so a seeded rng makes the result reproducible.
+//
+// Returns false, leaving the model untouched, on the same conditions as Fit
+// with K=k, or if sampleSize is smaller than k. A sampleSize larger than
+// len(vecs) fits on all of vecs.
+func (km *KMeans) FitSubsample(vecs [][]float64, k, sampleSize int, rng *rand.Rand) bool {
+	if sampleSize < k || !sameDim(vecs) {
+		return false
+	}
+	if rng == nil {
+		rng = rand.New(rand.NewSource(0))
+	}
+	if sampleSize > len(vecs) {
+		sampleSize = len(vecs)
+	}
+	sample := make([][]float64, sampleSize)
+	for i, index := range rng.Perm(len(vecs))[:sampleSize] {
+		sample[i] = vecs[index]
+	}
+
+	fitted := NewKMeans(NewKMeansArgs{
+		Metric:        km.metric,
+		KNNSearchFunc: km.knnSearchFunc,
+		KFNSearchFunc: km.kfnSearchFunc,
+	})
+	if !fitted.Fit(sample, FitArgs{K: k, MaxIter: defaultMaxIter, Rng: rng}) {
+		return false
+	}
+
+	payloads := make([]payloadContainer, len(vecs))
+	labels := make([]int, len(vecs))
+	for i, v := range vecs {
+		payloads[i] = &vecPayload{vec: v}
+		labels[i] = -1
+	}
+	assign(fitted.centroids, payloads, labels, km.metric, 0)
+	km.centroids, km.iterations, km.changes = fitted.centroids, fitted.iterations, fitted.changes
+	return true
+}
+
+// FitBestOf runs restarts independent fits of vecs with K=k, each seeded
+// at random from its own rng drawn from rng, and keeps the one with the
+// lowest inertia. This makes the result far less sensitive to an unlucky
+// initialization. A seeded rng makes the result reproducible.
+//
+// Returns false, leaving the model untouched, on the same conditions as Fit,
+// or if restarts is below 1.
+func (km *KMeans) FitBestOf(vecs [][]float64, k, maxIter, restarts int, rng *rand.Rand) bool {
+	if restarts < 1 {
+		return false
+	}
+	if rng == nil {
+		rng = rand.New(rand.NewSource(0))
+	}
+
+	var best *KMeans
+	for i := 0; i < restarts; i++ {
+		fitted := NewKMeans(NewKMeansArgs{
+			Metric:        km.metric,
+			KNNSearchFunc: km.knnSearchFunc,
+			KFNSearchFunc: km.kfnSearchFunc,
+		})
+		args := FitArgs{K: k, MaxIter: maxIter, Rng: rand.New(rand.NewSource(rng.Int63()))}
+		if !fitted.Fit(vecs, args) {
+			return false
+		}
+		if best == nil || fitted.Inertia() < best.Inertia() {
+			best = fitted
+		}
+	}
+	km.centroids, km.iterations, km.changes = best.centroids, best.iterations, best.changes
+	return true
+}
+
+// sameDim reports whether vecs are all non-nil and of equal length.
+func sameDim(vecs [][]float64) bool {
+	for _, v := range vecs {
+		if v == nil || len(v) != len(vecs[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// assign empties centroids and hands each payload to its nearest centroid
+// under metric, recording the centroid index in labels. With a positive
+// balance, distance is penalised by centroid size (see
+// FitArgs.BalanceFactor). Returns how many labels changed.
+func assign(
+	centroids []*Centroid,
+	payloads []payloadContainer,
+	labels []int,
+	metric mathutils.Metric,
+	balance float64,
+) int {
+	for _, c := range centroids {
+		c.clear()
+	}
+	var penalty func(c *Centroid) float64
+	if balance > 0 {
+		ideal := float64(len(payloads)) / float64(len(centroids))
+		penalty = func(c *Centroid) float64 {
+			return balance * float64(c.LenDP()) / ideal
+		}
+	}
+	changed := 0
+	for i, p := range payloads {
+		label := cheapestCentroid(centroids, p.Vec(), metric, penalty)
+		if label == -1 {
+			continue
+		}
+		centroids[label].AddPayload(p)
+		if label != labels[i] {
+			labels[i] = label
+			changed++
+		}
+	}
+	return changed
+}
+
+// nearestCentroid returns the index of the centroid closest to vec under
+// metric, lowest index on ties, or -1 if none can be compared with vec.
+func nearestCentroid(centroids []*Centroid, vec []float64, metric mathutils.Metric) int {
+	return cheapestCentroid(centroids, vec, metric, nil)
+}
+
+// cheapestCentroid is nearestCentroid where, if penalty is non-nil, the cost
+// of each centroid is its distance plus its penalty.
+func cheapestCentroid(
+	centroids []*Centroid,
+	vec []float64,
+	metric mathutils.Metric,
+	penalty func(c *Centroid) float64,
+) int {
+	best, bestDist := -1, 0.
+	for i, c := range centroids {
+		d, err := metric(c.vec, vec)
+		if err != nil {
+			continue
+		}
+		if penalty != nil {
+			d += penalty(c)
+		}
+		if best == -1 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// AddPayload routes p to the centroid nearest to its vector under the model
+// metric. Returns false if no centroid can be compared with the vector or
+// if that centroid rejects p (see Centroid.AddPayload).
+func (km *KMeans) AddPayload(p payloadContainer) bool {
+	if p == nil {
+		return false
+	}
+	i := nearestCentroid(km.centroids, p.Vec(), km.metric)
+	return i != -1 && km.centroids[i].AddPayload(p)
+}
+
+// Assign returns the index of the nearest centroid for each of vecs, or -1
+// for vectors that can't be compared with the centroids. The model is not
+// modified.
+func (km *KMeans) Assign(vecs [][]float64) []int {
+	labels := make([]int, len(vecs))
+	for i, v := range vecs {
+		labels[i] = nearestCentroid(km.centroids, v, km.metric)
+	}
+	return labels
+}
+
+// ReassignAll replaces the payloads of every centroid with vecs, each
+// handed to its nearest centroid as Assign would label it, without moving
+// any centroid. Useful to refresh memberships after the centroids changed,
+// e.g. after Prune. Vectors that can't be compared with the centroids are
+// dropped.
+func (km *KMeans) ReassignAll(vecs [][]float64) {
+	payloads := make([]payloadContainer, len(vecs))
+	labels := make([]int, len(vecs))
+	for i, v := range vecs {
+		payloads[i] = &vecPayload{vec: v}
+		labels[i] = -1
+	}
+	assign(km.centroids, payloads, labels, km.metric, 0)
+}
+
+// PredictProba returns soft assignment probabilities of vec to each
+// centroid, indexed like Iterate: a softmax over the negated distances
+// divided by the model temperature (see NewKMeansArgs.Temperature). The
+// probabilities sum to 1, and the nearest centroid gets the highest.
+// Centroids that can't be compared with vec get 0. Returns nil if no
+// centroid can be compared with vec.
+func (km *KMeans) PredictProba(vec []float64) []float64 {
+	dists := make([]float64, len(km.centroids))
+	minDist := math.Inf(1)
+	for i, c := range km.centroids {
+		d, err := km.metric(c.vec, vec)
+		if err != nil {
+			d = math.Inf(1)
+		}
+		dists[i], minDist = d, math.Min(minDist, d)
+	}
+	if math.IsInf(minDist, 1) {
+		return nil
+	}
+
+	// Shifting by the smallest distance keeps the exponentials in range.
+	var sum float64
+	for i, d := range dists {
+		dists[i] = math.Exp(-(d - minDist) / km.temperature)
+		sum += dists[i]
+	}
+	for i := range dists {
+		dists[i] /= sum
+	}
+	return dists
+}
+
+// Inertia returns the sum of the centroids' SSE. It uses the incrementally
+// tracked value (see Centroid.TrackedSSE), so it is cheap to call between
+// iterations.
+func (km *KMeans) Inertia() float64 {
+	return inertia(km.centroids)
+}
+
+// inertia returns the sum of the tracked SSE of centroids.
+func inertia(centroids []*Centroid) float64 {
+	var sum float64
+	for _, c := range centroids {
+		sum += c.TrackedSSE()
+	}
+	return sum
+}
+
+// Prune removes centroids holding fewer than minCount payloads, such as
+// spurious micro-clusters left by a fit, handing their payloads to the
+// nearest surviving centroids with DistributePayload. A centroid that ends
+// up holding payloads no survivor would take is kept, so no data is lost.
+// Nothing is pruned if no centroid holds minCount payloads. Returns the
+// number of centroids removed.
+func (km *KMeans) Prune(minCount int) int {
+	small := make([]bool, len(km.centroids))
+	var survivors []PayloadReceiver
+	for i, c := range km.centroids {
+		small[i] = c.LenDP() < minCount
+		if !small[i] {
+			survivors = append(survivors, c)
+		}
+	}
+	if len(survivors) == 0 {
+		return 0
+	}
+
+	kept := make([]*Centroid, 0, len(km.centroids))
+	for i, c := range km.centroids {
+		if small[i] {
+			c.DistributePayload(survivors, c.LenDP(), 0)
+		}
+		if !small[i] || c.LenDP() > 0 {
+			kept = append(kept, c)
+		}
+	}
+	removed := len(km.centroids) - len(kept)
+	km.centroids = kept
+	return removed
+}
+
+// CentroidDistances returns the matrix of distances between centroid
+// vectors under metric, indexed like Iterate; a nil metric means the model
+// metric; see mathutils.DistanceMatrix. Returns nil if metric fails for any
+// pair of centroids.
+func (km *KMeans) CentroidDistances(metric mathutils.Metric) [][]float64 {
+	if metric == nil {
+		metric = km.metric
+	}
+	vecs := make([][]float64, len(km.centroids))
+	for i, c := range km.centroids {
+		vecs[i] = c.vec
+	}
+	dist, err := mathutils.DistanceMatrix(vecs, metric)
+	if err != nil {
+		return nil
+	}
+	return dist
+}
+
+// Iterate calls fn with each centroid and its index, in order, stopping
+// early if fn returns false. Centroids may be inspected or modified through
+// fn, but the set of centroids itself is not exposed.
+func (km *KMeans) Iterate(fn func(index int, c *Centroid) bool) {
+	for i, c := range km.centroids {
+		if !fn(i, c) {
+			return
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package kmeans
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// twoGroups is a dataset with two well separated groups: the first three
+// vectors and the last three.
+var twoGroups = [][]float64{{0, 0}, {0, 1}, {1, 0}, {10, 10}, {10, 11}, {11, 10}}
+
+// fitTestModel fits a Euclidean model to vecs, failing the test on error.
+func fitTestModel(t *testing.T, vecs [][]float64, k int) *KMeans {
+	t.Helper()
+	km := NewKMeans(NewKMeansArgs{})
+	if !km.Fit(vecs, FitArgs{K: k, MaxIter: 100}) {
+		t.Fatal("fit failed")
+	}
+	return km
+}
+
+func TestKMeansFit(t *testing.T) {
+	km := fitTestModel(t, twoGroups, 2)
+	labels := km.Assign(twoGroups)
+	for i := 1; i < 3; i++ {
+		if labels[i] != labels[0] || labels[i+3] != labels[3] {
+			t.Fatalf("groups split: %v", labels)
+		}
+	}
+	if labels[0] == labels[3] {
+		t.Fatalf("groups merged: %v", labels)
+	}
+
+	total := 0
+	km.Iterate(func(_ int, c *Centroid) bool {
+		total += c.LenDP()
+		return true
+	})
+	if total != len(twoGroups) {
+		t.Errorf("centroids hold %d payloads, want %d", total, len(twoGroups))
+	}
+	// Each group is three points at squared distances 2/9, 5/9, 5/9.
+	if got, want := km.Inertia(), 2*(12./9); got-want > 1e-9 || want-got > 1e-9 {
+		t.Errorf("got inertia %v, want %v", got, want)
+	}
+}
+
+func TestKMeansFitInvalid(t *testing.T) {
+	km := NewKMeans(NewKMeansArgs{})
+	tests := map[string]struct {
+		vecs [][]float64
+		args FitArgs
+	}{
+		"k zero":       {twoGroups, FitArgs{K: 0, MaxIter: 10}},
+		"k too large":  {twoGroups, FitArgs{K: 7, MaxIter: 10}},
+		"no iter":      {twoGroups, FitArgs{K: 2, MaxIter: 0}},
+		"dim mismatch": {[][]float64{{1}, {1, 2}}, FitArgs{K: 1, MaxIter: 10}},
+		"nil vec":      {[][]float64{{1}, nil}, FitArgs{K: 1, MaxIter: 10}},
+		"checkpoints":  {twoGroups, FitArgs{K: 2, MaxIter: 10, CheckpointEvery: -1}},
+	}
+	for name, test := range tests {
+		if km.Fit(test.vecs, test.args) {
+			t.Errorf("%s: expected failure", name)
+		}
+	}
+}
+
+func TestKMeansIterateAll(t *testing.T) {
+	km := fitTestModel(t, twoGroups, 3)
+	var visited []int
+	km.Iterate(func(i int, c *Centroid) bool {
+		if c == nil {
+			t.Errorf("nil centroid at %d", i)
+		}
+		visited = append(visited, i)
+		return true
+	})
+	if len(visited) != 3 || visited[0] != 0 || visited[2] != 2 {
+		t.Errorf("visited %v, want [0 1 2]", visited)
+	}
+}
+
+func TestKMeansIterateEarlyStop(t *testing.T) {
+	km := fitTestModel(t, twoGroups, 3)
+	calls := 0
+	km.Iterate(func(i int, _ *Centroid) bool {
+		calls++
+		return i < 1
+	})
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+}
+
+// uniformVecs returns n uniformly random 2D vectors. Lloyd's algorithm
+// takes many small, shuffling steps on such structureless data.
+func uniformVecs(n int, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	vecs := make([][]float64, n)
+	for i := range vecs {
+		vecs[i] = []float64{rng.Float64(), rng.Float64()}
+	}
+	return vecs
+}
+
+func TestKMeansAssignmentChanges(t *testing.T) {
+	vecs := uniformVecs(500, 1)
+	km := NewKMeans(NewKMeansArgs{})
+	if !km.Fit(vecs, FitArgs{K: 5, MaxIter: 300}) {
+		t.Fatal("fit failed")
+	}
+	changes := km.AssignmentChanges()
+	if len(changes) != km.Iterations() {
+		t.Fatalf("got %d counts for %d iterations", len(changes), km.Iterations())
+	}
+	if changes[0] != len(vecs) || changes[len(changes)-1] != 0 {
+		t.Fatalf("got first %d and last %d, want %d and 0",
+			changes[0], changes[len(changes)-1], len(vecs))
+	}
+	// Counts may wobble, but the second half of the run should shuffle
+	// fewer vectors than the first reassignment did.
+	for i, n := range changes[len(changes)/2:] {
+		if n >= changes[1] {
+			t.Errorf("iteration %d changed %d, not below %d", len(changes)/2+i+1, n, changes[1])
+		}
+	}
+}
+
+func TestKMeansFitPatience(t *testing.T) {
+	vecs := uniformVecs(300, 0)
+	km := NewKMeans(NewKMeansArgs{})
+	km.Fit(vecs, FitArgs{K: 8, MaxIter: 1000})
+	full := km.Iterations()
+	if full < 10 {
+		t.Fatalf("dataset converged too quickly (%d iterations) to test patience", full)
+	}
+
+	// With an unreachable tolerance every iteration after the first is
+	// stagnant, so the fit stops after exactly Patience of them.
+	km.Fit(vecs, FitArgs{K: 8, MaxIter: 1000, Patience: 3, Tol: math.Inf(1)})
+	if got := km.Iterations(); got != 4 {
+		t.Errorf("infinite tol: got %d iterations, want 4", got)
+	}
+
+	km.Fit(vecs, FitArgs{K: 8, MaxIter: 1000, Patience: 2, Tol: 0.05})
+	if got := km.Iterations(); got >= full || got < 3 {
+		t.Errorf("got %d iterations, want in [3, %d)", got, full)
+	}
+}
+
+// blobVecs returns n vectors around each of centers, within +-spread on
+// each axis.
+func blobVecs(centers [][]float64, n int, spread float64, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	var vecs [][]float64
+	for _, center := range centers {
+		for i := 0; i < n; i++ {
+			v := make([]float64, len(center))
+			for d := range v {
+				v[d] = center[d] + (rng.Float64()*2-1)*spread
+			}
+			vecs = append(vecs, v)
+		}
+	}
+	return vecs
+}
+
+// samePartition reports whether two labelings group vectors identically,
+// regardless of which label each group got.
+func samePartition(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ab, ba := map[int]int{}, map[int]int{}
+	for i := range a {
+		if l, ok := ab[a[i]]; ok && l != b[i] {
+			return false
+		}
+		if l, ok := ba[b[i]]; ok && l != a[i] {
+			return false
+		}
+		ab[a[i]], ba[b[i]] = b[i], a[i]
+	}
+	return true
+}
+
+func TestKMeansFitSubsample(t *testing.T) {
+	// Two groups on a line, far apart compared with their spread: from any
+	// two distinct seeds, Lloyd's algorithm ends up separating them, so the
+	// result doesn't depend on the rng.
+	vecs := blobVecs([][]float64{{0}, {100}}, 200, 1, 1)
+	want := make([]int, len(vecs))
+	for i := 200; i < len(vecs); i++ {
+		want[i] = 1
+	}
+
+	full := NewKMeans(NewKMeansArgs{})
+	full.Fit(vecs, FitArgs{K: 2, MaxIter: 100, Rng: rand.New(rand.NewSource(1))})
+	if !samePartition(full.Assign(vecs), want) {
+		t.Fatal("full fit didn't separate the groups")
+	}
+
+	sub := NewKMeans(NewKMeansArgs{})
+	if !sub.FitSubsample(vecs, 2, 30, rand.New(rand.NewSource(1))) {
+		t.Fatal("subsample fit failed")
+	}
+	labels := sub.Assign(vecs)
+	if !samePartition(labels, want) {
+		t.Error("subsample fit didn't separate the groups")
+	}
+
+	total := 0
+	sub.Iterate(func(_ int, c *Centroid) bool {
+		total += c.LenDP()
+		return true
+	})
+	if total != len(vecs) {
+		t.Errorf("centroids hold %d payloads, want all %d", total, len(vecs))
+	}
+
+	again := NewKMeans(NewKMeansArgs{})
+	again.FitSubsample(vecs, 2, 30, rand.New(rand.NewSource(1)))
+	for i, l := range again.Assign(vecs) {
+		if l != labels[i] {
+			t.Fatal("same seed gave a different result")
+		}
+	}
+}
+
+func TestKMeansFitSubsampleInvalid(t *testing.T) {
+	km := NewKMeans(NewKMeansArgs{})
+	if km.FitSubsample(twoGroups, 3, 2, nil) {
+		t.Error("accepted sampleSize < k")
+	}
+}
+
+func TestKMeansFitBestOf(t *testing.T) {
+	// Many blobs give random seeding plenty of local optima to land in.
+	centers := [][]float64{{0, 0}, {10, 0}, {20, 0}, {0, 10}, {10, 10}, {20, 10}}
+	vecs := blobVecs(centers, 30, 2, 1)
+
+	km := NewKMeans(NewKMeansArgs{})
+	if !km.FitBestOf(vecs, 6, 100, 8, rand.New(rand.NewSource(3))) {
+		t.Fatal("fit failed")
+	}
+
+	// Replay the single runs with the seeds FitBestOf draws.
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 8; i++ {
+		single := NewKMeans(NewKMeansArgs{})
+		single.Fit(vecs, FitArgs{K: 6, MaxIter: 100, Rng: rand.New(rand.NewSource(rng.Int63()))})
+		if km.Inertia() > single.Inertia() {
+			t.Errorf("run %d: inertia %v below best-of %v", i, single.Inertia(), km.Inertia())
+		}
+	}
+
+	again := NewKMeans(NewKMeansArgs{})
+	again.FitBestOf(vecs, 6, 100, 8, rand.New(rand.NewSource(3)))
+	if !samePartition(km.Assign(vecs), again.Assign(vecs)) {
+		t.Error("same seed gave different results")
+	}
+
+	if km.FitBestOf(vecs, 6, 100, 0, nil) {
+		t.Error("accepted restarts < 1")
+	}
+}
+
+func TestKMeansReassignAll(t *testing.T) {
+	vecs := blobVecs([][]float64{{0, 0}, {10, 0}, {0, 10}}, 20, 2, 1)
+	km := fitTestModel(t, vecs, 3)
+	centroidVecs := func() (vecs [][]float64) {
+		km.Iterate(func(_ int, c *Centroid) bool {
+			vecs = append(vecs, c.VecCopy())
+			return true
+		})
+		return vecs
+	}
+	before := centroidVecs()
+
+	// The old members are replaced, not added to.
+	fresh := blobVecs([][]float64{{5, 5}, {0, 0}}, 10, 4, 2)
+	km.ReassignAll(fresh)
+
+	want := make([][][]float64, 3)
+	for i, label := range km.Assign(fresh) {
+		want[label] = append(want[label], fresh[i])
+	}
+	km.Iterate(func(i int, c *Centroid) bool {
+		if got := dpVecs(c); len(got)+len(want[i]) > 0 && !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("centroid %d: got %v, want %v", i, got, want[i])
+		}
+		return true
+	})
+	if !reflect.DeepEqual(centroidVecs(), before) {
+		t.Error("centroids moved")
+	}
+}
+
+func TestKMeansFitChangeFraction(t *testing.T) {
+	vecs := uniformVecs(2000, 3)
+	args := FitArgs{K: 20, MaxIter: 8}
+
+	strict := NewKMeans(NewKMeansArgs{})
+	strict.Fit(vecs, args)
+	if got := strict.Iterations(); got != args.MaxIter {
+		t.Fatalf("strict fit ran %d iterations, want MaxIter", got)
+	}
+
+	args.ChangeFraction = 0.05
+	loose := NewKMeans(NewKMeansArgs{})
+	if !loose.Fit(vecs, args) {
+		t.Fatal("fit failed")
+	}
+	changes := loose.AssignmentChanges()
+	if len(changes) >= args.MaxIter {
+		t.Fatalf("loose fit ran to MaxIter: %v", changes)
+	}
+	if last := changes[len(changes)-1]; last == 0 || float64(last) >= 0.05*float64(len(vecs)) {
+		t.Errorf("stopped at %d changes, want in (0, 5%%)", last)
+	}
+
+	for _, f := range []float64{-0.1, 1} {
+		args.ChangeFraction = f
+		if loose.Fit(vecs, args) {
+			t.Errorf("accepted ChangeFraction %v", f)
+		}
+	}
+}
+
+// sizeSpread returns the difference between the largest and smallest
+// cluster of km.
+func sizeSpread(km *KMeans) int {
+	lo, hi := math.MaxInt, 0
+	km.Iterate(func(_ int, c *Centroid) bool {
+		lo, hi = min(lo, c.LenDP()), max(hi, c.LenDP())
+		return true
+	})
+	return hi - lo
+}
+
+func TestKMeansFitBalanceFactor(t *testing.T) {
+	vecs := append(
+		blobVecs([][]float64{{0, 0}}, 150, 3, 1),
+		blobVecs([][]float64{{10, 0}}, 30, 3, 2)...,
+	)
+	plain := NewKMeans(NewKMeansArgs{})
+	plain.Fit(vecs, FitArgs{K: 2, MaxIter: 50})
+	balanced := NewKMeans(NewKMeansArgs{})
+	balanced.Fit(vecs, FitArgs{K: 2, MaxIter: 50, BalanceFactor: 20})
+
+	if p, b := sizeSpread(plain), sizeSpread(balanced); b >= p {
+		t.Errorf("balanced size spread %d not below plain %d", b, p)
+	}
+	if b := sizeSpread(balanced); b > 30 {
+		t.Errorf("balanced size spread %d, want <= 30", b)
+	}
+}
+
+func TestKMeansAddPayload(t *testing.T) {
+	km := fitTestModel(t, twoGroups, 2)
+	target := km.Assign([][]float64{{9, 9}})[0]
+	before := km.centroids[target].LenDP()
+
+	if !km.AddPayload(&testPayload{vec: []float64{9, 9}}) {
+		t.Fatal("rejected valid payload")
+	}
+	if got := km.centroids[target].LenDP(); got != before+1 {
+		t.Errorf("nearest centroid holds %d, want %d", got, before+1)
+	}
+	if km.AddPayload(&testPayload{vec: []float64{9}}) || km.AddPayload(nil) {
+		t.Error("accepted invalid payload")
+	}
+}
+
+func TestKMeansCentroidDistances(t *testing.T) {
+	km := newTestModel(
+		newTestCentroid(t, []float64{0, 0}),
+		newTestCentroid(t, []float64{3, 4}),
+		newTestCentroid(t, []float64{0, 1}),
+	)
+	want := [][]float64{
+		{0, 5, 1},
+		{5, 0, math.Sqrt(18)},
+		{1, math.Sqrt(18), 0},
+	}
+	if got := km.CentroidDistances(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	cosine, _ := mathutils.MetricByName("cosine")
+	got := km.CentroidDistances(cosine)
+	if got[1][2] != got[2][1] || math.Abs(got[1][2]-0.2) > 1e-12 {
+		t.Errorf("cosine: got %v and %v, want 0.2", got[1][2], got[2][1])
+	}
+
+	fail := func(v1, v2 []float64) (float64, error) { return 0, mathutils.ErrNilVec }
+	if got := km.CentroidDistances(fail); got != nil {
+		t.Errorf("failing metric: got %v, want nil", got)
+	}
+}
+
+func TestKMeansPrune(t *testing.T) {
+	left := newTestCentroid(t, []float64{0}, []float64{-1}, []float64{0}, []float64{1})
+	tiny := newTestCentroid(t, []float64{4}, []float64{4})
+	right := newTestCentroid(t, []float64{10}, []float64{9}, []float64{10}, []float64{11})
+	km := newTestModel(left, tiny, right)
+
+	if got := km.Prune(2); got != 1 {
+		t.Fatalf("pruned %d centroids, want 1", got)
+	}
+	var got []*Centroid
+	km.Iterate(func(_ int, c *Centroid) bool {
+		got = append(got, c)
+		return true
+	})
+	if len(got) != 2 || got[0] != left || got[1] != right {
+		t.Fatalf("got centroids %v, want left and right", got)
+	}
+	// The tiny cluster's payload is absorbed by its nearer neighbour.
+	if want := [][]float64{{-1}, {0}, {1}, {4}}; !reflect.DeepEqual(dpVecs(left), want) {
+		t.Errorf("left holds %v, want %v", dpVecs(left), want)
+	}
+	if right.LenDP() != 3 {
+		t.Errorf("right holds %d payloads, want 3", right.LenDP())
+	}
+}
+
+func TestKMeansPruneKeepsData(t *testing.T) {
+	// No centroid is large enough to survive: nothing is pruned.
+	km := newTestModel(newTestCentroid(t, []float64{0}, []float64{0}))
+	if got := km.Prune(5); got != 0 {
+		t.Errorf("pruned %d centroids with no survivors", got)
+	}
+
+	// The survivor rejects the small centroid's payload (wrong dimension
+	// for it), so the small centroid is kept with its payload.
+	odd := newTestCentroid(t, []float64{0, 0}, []float64{1, 1})
+	big := newTestCentroid(t, []float64{5}, []float64{5}, []float64{6})
+	km = newTestModel(odd, big)
+	if got := km.Prune(2); got != 0 {
+		t.Errorf("pruned %d centroids, want 0", got)
+	}
+	if odd.LenDP() != 1 {
+		t.Errorf("small centroid holds %d payloads, want 1", odd.LenDP())
+	}
+}
+
+func TestKMeansPredictProba(t *testing.T) {
+	centroids := []*Centroid{
+		newTestCentroid(t, []float64{0}),
+		newTestCentroid(t, []float64{2}),
+		newTestCentroid(t, []float64{10}),
+	}
+	maxProba := map[float64]float64{}
+	for _, temp := range []float64{0.5, 1, 4} {
+		km := NewKMeans(NewKMeansArgs{Temperature: temp})
+		km.centroids = centroids
+
+		probs := km.PredictProba([]float64{1.5})
+		var sum float64
+		for _, p := range probs {
+			sum += p
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Errorf("T=%v: probabilities %v sum to %v", temp, probs, sum)
+		}
+		if !(probs[1] > probs[0] && probs[0] > probs[2]) {
+			t.Errorf("T=%v: got %v, want nearest first", temp, probs)
+		}
+		maxProba[temp] = probs[1]
+	}
+	if !(maxProba[0.5] > maxProba[1] && maxProba[1] > maxProba[4]) {
+		t.Errorf("higher temperature didn't soften assignment: %v", maxProba)
+	}
+
+	km := newTestModel(centroids...)
+	if got := km.PredictProba([]float64{1, 2}); got != nil {
+		t.Errorf("incomparable vec: got %v, want nil", got)
+	}
+	// Huge distances must not underflow every exponential to 0.
+	if got := km.PredictProba([]float64{1e6}); math.IsNaN(got[0]) || got[2] < got[1] {
+		t.Errorf("far vec: got %v", got)
+	}
+}
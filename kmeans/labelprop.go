@@ -0,0 +1,150 @@
+package kmeans
+
+import (
+	"math"
+	"sort"
+
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// labelPropagationTol is the largest change in any label score for which
+// LabelPropagation considers the scores settled.
+const labelPropagationTol = 1e-9
+
+// edge is a weighted link to vertex to in a similarity graph.
+type edge struct {
+	to     int
+	weight float64
+}
+
+// LabelPropagation labels vecs semi-supervised from a few known labels:
+// seeds maps indexes into vecs to their label. It links every vector to its
+// nNeighbors nearest under metric, in both directions, with edges weighted
+// by similarity 1/(1+d). Then every unseeded vector repeatedly takes, for
+// each label, the weighted mean of its neighbours' scores, while seeds keep
+// their own label, until the scores settle or 300 rounds have run. Each
+// vector ends up with its highest-scoring label, lowest label on ties;
+// vectors the seeds' labels don't reach get -1.
+//
+// Returns nil if nNeighbors is less than 1, metric is nil, vecs holds nil
+// vectors or vectors of differing dimension, or a seed index is out of
+// range.
+func LabelPropagation(vecs [][]float64, seeds map[int]int, nNeighbors int, metric mathutils.Metric) []int {
+	if nNeighbors < 1 || metric == nil || !sameDim(vecs) {
+		return nil
+	}
+	// Scores are indexed by class, the position of a label among the
+	// distinct seed labels in ascending order.
+	classIndex := make(map[int]int)
+	for i, label := range seeds {
+		if i < 0 || i >= len(vecs) {
+			return nil
+		}
+		classIndex[label] = 0
+	}
+	classes := make([]int, 0, len(classIndex))
+	for label := range classIndex {
+		classes = append(classes, label)
+	}
+	sort.Ints(classes)
+	for c, label := range classes {
+		classIndex[label] = c
+	}
+
+	graph := knnGraph(vecs, nNeighbors, metric)
+	scores := make([][]float64, len(vecs))
+	for i := range scores {
+		scores[i] = make([]float64, len(classIndex))
+		if label, ok := seeds[i]; ok {
+			scores[i][classIndex[label]] = 1
+		}
+	}
+	next := make([][]float64, len(vecs))
+	for i := range next {
+		next[i] = append([]float64(nil), scores[i]...)
+	}
+	for iter := 0; iter < defaultMaxIter; iter++ {
+		change := 0.
+		for i, edges := range graph {
+			if _, ok := seeds[i]; ok {
+				continue
+			}
+			var total float64
+			for c := range next[i] {
+				next[i][c] = 0
+			}
+			for _, e := range edges {
+				total += e.weight
+				for c, s := range scores[e.to] {
+					next[i][c] += e.weight * s
+				}
+			}
+			for c := range next[i] {
+				if total > 0 {
+					next[i][c] /= total
+				}
+				change = math.Max(change, math.Abs(next[i][c]-scores[i][c]))
+			}
+		}
+		scores, next = next, scores
+		if change < labelPropagationTol {
+			break
+		}
+		// Seeds are skipped above, so carry their scores over.
+		for i := range seeds {
+			copy(next[i], scores[i])
+		}
+	}
+
+	labels := make([]int, len(vecs))
+	for i, s := range scores {
+		labels[i] = -1
+		best := 0.
+		for c, score := range s {
+			if score > best {
+				labels[i], best = classes[c], score
+			}
+		}
+	}
+	return labels
+}
+
+// knnGraph links every vector of vecs to its n nearest others under metric,
+// and those back to it, weighting each edge by the similarity of its ends
+// (see similarity). Edges are listed in ascending order of the vertex they
+// lead to, so traversal is deterministic.
+func knnGraph(vecs [][]float64, n int, metric mathutils.Metric) [][]edge {
+	knn := searchutils.KNNByMetric(metric)
+	adjacent := make([]map[int]bool, len(vecs))
+	for i := range adjacent {
+		adjacent[i] = make(map[int]bool)
+	}
+	for i, v := range vecs {
+		// Hide v from its own search; search funcs skip nil vectors.
+		j := 0
+		others := func() ([]float64, bool) {
+			if j >= len(vecs) {
+				return nil, false
+			}
+			j++
+			if j-1 == i {
+				return nil, true
+			}
+			return vecs[j-1], true
+		}
+		for _, neighbour := range knn(v, others, n) {
+			adjacent[i][neighbour] = true
+			adjacent[neighbour][i] = true
+		}
+	}
+
+	graph := make([][]edge, len(vecs))
+	for i, neighbours := range adjacent {
+		for j := range neighbours {
+			graph[i] = append(graph[i], edge{to: j, weight: similarity(metric, vecs[i], vecs[j])})
+		}
+		sort.Slice(graph[i], func(a, b int) bool { return graph[i][a].to < graph[i][b].to })
+	}
+	return graph
+}
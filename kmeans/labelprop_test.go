@@ -0,0 +1,64 @@
+package kmeans
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+func TestLabelPropagation(t *testing.T) {
+	centers := [][]float64{{0, 0}, {10, 0}, {5, 10}}
+	vecs := blobVecs(centers, 40, 2, 1)
+	// Labels are arbitrary; two seeds per blob.
+	classes := []int{7, -3, 12}
+	seeds := map[int]int{}
+	for b, label := range classes {
+		seeds[b*40], seeds[b*40+17] = label, label
+	}
+
+	labels := LabelPropagation(vecs, seeds, 5, mathutils.EuclideanDistance)
+	if len(labels) != len(vecs) {
+		t.Fatalf("got %d labels, want %d", len(labels), len(vecs))
+	}
+	correct := 0
+	for i, label := range labels {
+		if label == classes[i/40] {
+			correct++
+		}
+	}
+	if accuracy := float64(correct) / float64(len(vecs)); accuracy < 0.95 {
+		t.Errorf("got accuracy %v, want at least 0.95", accuracy)
+	}
+	for i, label := range seeds {
+		if labels[i] != label {
+			t.Errorf("seed %d: got label %d, want %d", i, labels[i], label)
+		}
+	}
+}
+
+func TestLabelPropagationUnreached(t *testing.T) {
+	// With one neighbour, the two far apart pairs form separate
+	// components, and only the first holds a seed.
+	vecs := [][]float64{{0}, {1}, {100}, {101}}
+	labels := LabelPropagation(vecs, map[int]int{0: 4}, 1, mathutils.EuclideanDistance)
+	if want := []int{4, 4, -1, -1}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("got %v, want %v", labels, want)
+	}
+}
+
+func TestLabelPropagationInvalid(t *testing.T) {
+	vecs := [][]float64{{0}, {1}}
+	euc := mathutils.EuclideanDistance
+	tests := map[string][]int{
+		"no neighbours": LabelPropagation(vecs, map[int]int{0: 1}, 0, euc),
+		"nil metric":    LabelPropagation(vecs, map[int]int{0: 1}, 1, nil),
+		"bad seed":      LabelPropagation(vecs, map[int]int{2: 1}, 1, euc),
+		"dim mismatch":  LabelPropagation([][]float64{{0}, {1, 2}}, map[int]int{0: 1}, 1, euc),
+	}
+	for name, got := range tests {
+		if got != nil {
+			t.Errorf("%s: got %v, want nil", name, got)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package kmeans
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// centroidState is the serialized form of a Centroid, shared by its JSON
+// and gob encodings.
+type centroidState struct {
+	ID         string         `json:"id"`
+	Vec        []float64      `json:"vec"`
+	DataPoints []payloadState `json:"dataPoints"`
+}
+
+// payloadState is the serialized form of a payload held by a Centroid.
+type payloadState struct {
+	Vec []float64 `json:"vec"`
+	// Expired is whether the payload had expired when it was marshalled.
+	Expired bool `json:"expired,omitempty"`
+	// Created is set for payloads implementing common.Timestamped.
+	Created *time.Time `json:"created,omitempty"`
+	// Type and Data are set for payloads implementing common.WirePayload.
+	Type string `json:"type,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// storedPayload is a payload decoded by Centroid.UnmarshalJSON or
+// Centroid.GobDecode.
+type storedPayload struct {
+	payloadState
+}
+
+func (p *storedPayload) Vec() []float64 { return p.payloadState.Vec }
+func (p *storedPayload) Expired() bool  { return p.payloadState.Expired }
+
+// Created returns the creation time the payload was marshalled with, or the
+// zero time if it had none.
+func (p *storedPayload) Created() time.Time {
+	if p.payloadState.Created == nil {
+		return time.Time{}
+	}
+	return *p.payloadState.Created
+}
+
+// newPayloadState returns the serialized form of p.
+func newPayloadState(p payloadContainer) (payloadState, error) {
+	if sp, ok := p.(*storedPayload); ok {
+		return sp.payloadState, nil
+	}
+	ps := payloadState{Vec: p.Vec(), Expired: p.Expired()}
+	if tp, ok := common.Unwrap(p).(common.Timestamped); ok {
+		created := tp.Created()
+		ps.Created = &created
+	}
+	if wp, ok := common.Unwrap(p).(common.WirePayload); ok {
+		data, err := wp.MarshalBinary()
+		if err != nil {
+			return payloadState{}, err
+		}
+		ps.Type, ps.Data = wp.TypeName(), data
+	}
+	return ps, nil
+}
+
+// state returns the serialized form of c.
+func (c *Centroid) state() (centroidState, error) {
+	cs := centroidState{ID: c.id, Vec: c.vec, DataPoints: make([]payloadState, len(c.DataPoints))}
+	for i, p := range c.DataPoints {
+		ps, err := newPayloadState(p)
+		if err != nil {
+			return centroidState{}, err
+		}
+		cs.DataPoints[i] = ps
+	}
+	return cs, nil
+}
+
+// newPayload returns the payload ps encodes: built by the PayloadFactory of
+// c if ps has a wire type and c has a factory, and a storedPayload
+// otherwise.
+func (c *Centroid) newPayload(ps payloadState) (payloadContainer, error) {
+	if ps.Type == "" || c.factory == nil {
+		return &storedPayload{ps}, nil
+	}
+	p, ok := c.factory.New(ps.Type, ps.Data)
+	if !ok {
+		return nil, fmt.Errorf("kmeans: decoding centroid: can't build payload of type %q", ps.Type)
+	}
+	if p.Vec() == nil {
+		return nil, errors.New("kmeans: decoding centroid: payload without vector")
+	}
+	// The factory builds the payload as it was added; normalize it like
+	// AddPayload did.
+	if c.autoNormalize && mathutils.Norm(p.Vec()) != 0 {
+		return newNormalizedPayload(p), nil
+	}
+	return p, nil
+}
+
+// restore replaces the state of c with cs; see UnmarshalJSON.
+func (c *Centroid) restore(cs centroidState) error {
+	if cs.Vec == nil {
+		return errors.New("kmeans: decoding centroid: no vector")
+	}
+	dataPoints := make([]payloadContainer, len(cs.DataPoints))
+	for i, ps := range cs.DataPoints {
+		p, err := c.newPayload(ps)
+		if err != nil {
+			return err
+		}
+		if len(p.Vec()) != len(cs.Vec) {
+			return errors.New("kmeans: decoding centroid: payload dimension mismatch")
+		}
+		dataPoints[i] = p
+	}
+
+	if c.metric == nil {
+		c.metric = mathutils.EuclideanDistance
+	}
+	if c.clock == nil {
+		c.clock = time.Now
+	}
+	if c.updater == nil {
+		c.updater = MeanUpdater{}
+	}
+	c.id, c.vec, c.DataPoints = cs.ID, cs.Vec, dataPoints
+	if c.id == "" {
+		c.id = vecID(c.vec)
+	}
+	c.sseStale = true
+	c.sum, c.sumStale = mathutils.RunningSum{}, true
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. It encodes the ID, the vector and,
+// for every payload, its vector along with whether it had expired, its
+// creation time (for common.Timestamped payloads) and its wire encoding
+// (for common.WirePayload payloads). The configuration, such as search
+// funcs and Metric, is not encoded.
+func (c *Centroid) MarshalJSON() ([]byte, error) {
+	cs, err := c.state()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cs)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the state of c with
+// one encoded by MarshalJSON. Payloads encoded with a wire type are rebuilt
+// by the PayloadFactory of c (see NewCentroidArgs.PayloadFactory), if it
+// has one, and decoding fails if the factory can't build them. Other
+// payloads are decoded into stand-ins that report the vector, creation
+// time and wire encoding they were marshalled with, and whose expiry is
+// frozen as of marshalling; marshalling them again encodes them unchanged.
+//
+// Decoding into a centroid created by NewCentroid keeps its configuration.
+// A zero Centroid gets the defaults of NewCentroid, except that it has no
+// search funcs: attach them with AttachSearch or AttachSearchByName before
+// searching it.
+func (c *Centroid) UnmarshalJSON(data []byte) error {
+	var cs centroidState
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return err
+	}
+	return c.restore(cs)
+}
+
+// GobEncode implements gob.GobEncoder, encoding what MarshalJSON does, for
+// shipping centroids between nodes.
+func (c *Centroid) GobEncode() ([]byte, error) {
+	cs, err := c.state()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cs); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, decoding like UnmarshalJSON: search
+// funcs must be attached with AttachSearch or AttachSearchByName before a
+// decoded zero Centroid is searched.
+func (c *Centroid) GobDecode(data []byte) error {
+	var cs centroidState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cs); err != nil {
+		return err
+	}
+	return c.restore(cs)
+}
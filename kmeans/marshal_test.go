@@ -0,0 +1,200 @@
+package kmeans
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// wirePayload is a testPayload implementing common.WirePayload.
+type wirePayload struct {
+	testPayload
+	name string
+}
+
+func (p *wirePayload) TypeName() string               { return "wire" }
+func (p *wirePayload) MarshalBinary() ([]byte, error) { return []byte(p.name), nil }
+
+func TestCentroidJSON(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := newTestCentroid(t, []float64{1, 2}, []float64{0, 1})
+	c.AddPayload(&timedPayload{testPayload{vec: []float64{2, 3}}, created})
+	c.AddPayload(&wirePayload{testPayload{vec: []float64{4, 5}}, "w"})
+	c.AddPayload(&testPayload{vec: []float64{6, 7}})
+	c.DataPoints[3].(*testPayload).expired = true
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Centroid
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID() != c.ID() || !reflect.DeepEqual(decoded.Vec(), c.Vec()) {
+		t.Errorf("got %v, want %v", &decoded, c)
+	}
+	if !reflect.DeepEqual(dpVecs(&decoded), dpVecs(c)) {
+		t.Errorf("got payloads %v, want %v", dpVecs(&decoded), dpVecs(c))
+	}
+	for i, p := range decoded.DataPoints {
+		if p.Expired() != (i == 3) {
+			t.Errorf("payload %d: got expired %v", i, p.Expired())
+		}
+	}
+	if got := decoded.DataPoints[1].(*storedPayload).Created(); !got.Equal(created) {
+		t.Errorf("got created %v, want %v", got, created)
+	}
+	if sp := decoded.DataPoints[2].(*storedPayload); sp.Type != "wire" || string(sp.Data) != "w" {
+		t.Errorf("got type %q, data %q, want wire, w", sp.Type, sp.Data)
+	}
+
+	again, err := json.Marshal(&decoded)
+	if err != nil || string(again) != string(data) {
+		t.Errorf("re-marshalled to %s, want %s", again, data)
+	}
+
+	if !decoded.MoveVector() || decoded.SSE() == 0 {
+		t.Error("decoded centroid not usable")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("searched without search funcs")
+			}
+		}()
+		decoded.DrainOrdered(1)
+	}()
+	decoded.AttachSearch(searchutils.KNNEuc, searchutils.KFNEuc)
+	if got := decoded.KNNLookup([]float64{0, 1}, 1, false); len(got) != 1 {
+		t.Errorf("got %d results after attaching search", len(got))
+	}
+}
+
+func TestCentroidUnmarshalJSONKeepsConfig(t *testing.T) {
+	var rejected int
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		OnReject:      func(payloadContainer, string) { rejected++ },
+	})
+	data := `{"id":"x","vec":[3],"dataPoints":[{"vec":[1]},{"vec":[2]}]}`
+	if err := json.Unmarshal([]byte(data), c); err != nil {
+		t.Fatal(err)
+	}
+	if c.ID() != "x" || c.LenDP() != 2 || c.Vec()[0] != 3 {
+		t.Errorf("got %v", c)
+	}
+	if got := c.KNNLookup([]float64{2}, 1, false); len(got) != 1 || got[0].Vec()[0] != 2 {
+		t.Error("lost search funcs")
+	}
+	c.AddPayload(nil)
+	c.AddPayload(&testPayload{vec: []float64{1, 1}})
+	if rejected != 1 {
+		t.Errorf("got %d rejections, want 1", rejected)
+	}
+}
+
+func TestCentroidJSONPayloadFactory(t *testing.T) {
+	vecs := map[string][]float64{"a": {4, 5}, "b": {6, 7}}
+	factory := &common.PayloadFactory{}
+	factory.Register("wire", func(data []byte) common.PayloadContainer {
+		vec, ok := vecs[string(data)]
+		if !ok {
+			return nil
+		}
+		return &wirePayload{testPayload{vec: vec}, string(data)}
+	})
+	newCentroid := func(factory *common.PayloadFactory) *Centroid {
+		c, _ := NewCentroid(NewCentroidArgs{
+			InitVec:        []float64{1, 2},
+			Metric:         mathutils.EuclideanDistance,
+			PayloadFactory: factory,
+		})
+		return c
+	}
+
+	c := newCentroid(nil)
+	c.AddPayload(&wirePayload{testPayload{vec: []float64{4, 5}}, "a"})
+	c.AddPayload(&testPayload{vec: []float64{0, 1}})
+	c.AddPayload(&wirePayload{testPayload{vec: []float64{6, 7}}, "b"})
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := newCentroid(factory)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 2} {
+		p, ok := decoded.DataPoints[i].(*wirePayload)
+		if !ok || !reflect.DeepEqual(p, c.DataPoints[i]) {
+			t.Errorf("payload %d: got %#v, want %#v", i, decoded.DataPoints[i], c.DataPoints[i])
+		}
+	}
+	if _, ok := decoded.DataPoints[1].(*storedPayload); !ok {
+		t.Errorf("untyped payload: got %T, want a stand-in", decoded.DataPoints[1])
+	}
+
+	if err := json.Unmarshal(data, newCentroid(&common.PayloadFactory{})); err == nil {
+		t.Error("decoded a payload type the factory doesn't know")
+	}
+}
+
+func TestCentroidUnmarshalJSONInvalid(t *testing.T) {
+	tests := map[string]string{
+		"malformed":     `{"vec":`,
+		"no vec":        `{"dataPoints":[]}`,
+		"dim mismatch":  `{"vec":[1,2],"dataPoints":[{"vec":[1]}]}`,
+		"nil dp vector": `{"vec":[1],"dataPoints":[{}]}`,
+	}
+	for name, data := range tests {
+		var c Centroid
+		if err := json.Unmarshal([]byte(data), &c); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCentroidGob(t *testing.T) {
+	c := newTestCentroid(t, []float64{1, 2}, []float64{0, 1}, []float64{2, 3}, []float64{4, 5})
+	c.AddPayload(&wirePayload{testPayload{vec: []float64{6, 7}}, "w"})
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(c); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Centroid
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID() != c.ID() || !reflect.DeepEqual(decoded.Vec(), c.Vec()) || decoded.LenDP() != c.LenDP() {
+		t.Errorf("got %v, want %v", &decoded, c)
+	}
+	if !reflect.DeepEqual(dpVecs(&decoded), dpVecs(c)) {
+		t.Errorf("got payloads %v, want %v", dpVecs(&decoded), dpVecs(c))
+	}
+	if sp := decoded.DataPoints[3].(*storedPayload); sp.Type != "wire" || string(sp.Data) != "w" {
+		t.Errorf("got type %q, data %q, want wire, w", sp.Type, sp.Data)
+	}
+	if !decoded.AttachSearchByName("euclidean") {
+		t.Fatal("failed to attach search")
+	}
+	if got := decoded.DrainOrdered(1); len(got) != 1 || got[0].Vec()[0] != 6 {
+		t.Errorf("got %v after attaching search", payloadVecs(got))
+	}
+
+	if err := decoded.GobDecode([]byte("garbage")); err == nil {
+		t.Error("decoded garbage")
+	}
+}
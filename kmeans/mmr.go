@@ -0,0 +1,68 @@
+package kmeans
+
+import "math"
+
+// KNNLookupDiverse is KNNLookup with maximal marginal relevance reranking,
+// for results that aren't k near-duplicates of each other. Payloads are
+// picked one at a time, each maximising
+//
+//	lambda*relevance - (1-lambda)*redundancy
+//
+// where relevance is the negated distance to vec and redundancy the negated
+// distance to the closest payload already picked, both under the centroid
+// Metric. lambda=1 gives plain relevance order; lower values trade relevance
+// for diversity. Every non-expired payload is a candidate, so a lookup costs
+// O(LenDP*k) distance computations. If drain is true the returned payloads
+// are also removed from the centroid.
+func (c *Centroid) KNNLookupDiverse(vec []float64, k int, lambda float64, drain bool) []payloadContainer {
+	candidates := c.validIndexes(c.knn(vec, c.payloadVecGenerator(), c.LenDP()))
+	relevance := make([]float64, len(candidates))
+	for i, index := range candidates {
+		d, err := c.metric(vec, c.DataPoints[index].Vec())
+		if err != nil {
+			d = math.Inf(1)
+		}
+		relevance[i] = -d
+	}
+
+	// closest[i] is the distance from candidate i to the nearest pick.
+	closest := make([]float64, len(candidates))
+	for i := range closest {
+		closest[i] = math.Inf(1)
+	}
+	picked := make([]bool, len(candidates))
+	var indexes []int
+	for len(indexes) < k && len(indexes) < len(candidates) {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range candidates {
+			if picked[i] {
+				continue
+			}
+			score := lambda * relevance[i]
+			if len(indexes) > 0 {
+				score += (1 - lambda) * closest[i]
+			}
+			if best == -1 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		picked[best] = true
+		indexes = append(indexes, candidates[best])
+
+		pv := c.DataPoints[candidates[best]].Vec()
+		for i := range candidates {
+			if d, err := c.metric(pv, c.DataPoints[candidates[i]].Vec()); err == nil {
+				closest[i] = math.Min(closest[i], d)
+			}
+		}
+	}
+
+	if drain {
+		return c.drainIndexes(indexes)
+	}
+	result := make([]payloadContainer, len(indexes))
+	for i, index := range indexes {
+		result[i] = c.DataPoints[index]
+	}
+	return result
+}
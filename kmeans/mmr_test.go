@@ -0,0 +1,33 @@
+package kmeans
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCentroidKNNLookupDiverse(t *testing.T) {
+	c := newTestCentroid(t, []float64{0},
+		[]float64{1.02}, []float64{3}, []float64{1}, []float64{1.01})
+
+	got := payloadVecs(c.KNNLookupDiverse([]float64{0}, 2, 1, false))
+	if want := [][]float64{{1}, {1.01}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lambda=1: got %v, want %v", got, want)
+	}
+	want := payloadVecs(c.KNNLookup([]float64{0}, 3, false))
+	if got := payloadVecs(c.KNNLookupDiverse([]float64{0}, 3, 1, false)); !reflect.DeepEqual(got, want) {
+		t.Errorf("lambda=1: got %v, want KNNLookup order %v", got, want)
+	}
+
+	got = payloadVecs(c.KNNLookupDiverse([]float64{0}, 2, 0.3, false))
+	if want := [][]float64{{1}, {3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lambda=0.3: got %v, want %v", got, want)
+	}
+	if c.LenDP() != 4 {
+		t.Errorf("non-draining lookup changed LenDP to %d", c.LenDP())
+	}
+
+	c.KNNLookupDiverse([]float64{0}, 2, 0.3, true)
+	if want := [][]float64{{1.02}, {1.01}}; !reflect.DeepEqual(dpVecs(c), want) {
+		t.Errorf("remaining %v, want %v", dpVecs(c), want)
+	}
+}
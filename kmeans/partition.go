@@ -0,0 +1,47 @@
+package kmeans
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// PartitionByProjection assigns each of vecs to one of shards partitions,
+// for load-balanced sharding before clustering. Vectors are projected onto
+// a random direction drawn from seed and cut into shards bands of equal
+// size at quantile thresholds, so partition sizes differ by at most one and
+// nearby vectors tend to share a partition. The result depends only on vecs,
+// shards and seed.
+//
+// Returns nil if shards is less than 1 or if vecs holds nil vectors or
+// vectors of differing dimension.
+func PartitionByProjection(vecs [][]float64, shards int, seed int64) []int {
+	if shards < 1 || !sameDim(vecs) {
+		return nil
+	}
+	if len(vecs) == 0 {
+		return []int{}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	direction := make([]float64, len(vecs[0]))
+	for i := range direction {
+		direction[i] = rng.NormFloat64()
+	}
+	projections := make([]float64, len(vecs))
+	order := make([]int, len(vecs))
+	for i, v := range vecs {
+		for j, x := range v {
+			projections[i] += x * direction[j]
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return projections[order[a]] < projections[order[b]]
+	})
+
+	labels := make([]int, len(vecs))
+	for rank, index := range order {
+		labels[index] = rank * shards / len(vecs)
+	}
+	return labels
+}
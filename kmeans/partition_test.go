@@ -0,0 +1,45 @@
+package kmeans
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartitionByProjection(t *testing.T) {
+	vecs := uniformVecs(1003, 1)
+	labels := PartitionByProjection(vecs, 4, 7)
+	if len(labels) != len(vecs) {
+		t.Fatalf("got %d labels, want %d", len(labels), len(vecs))
+	}
+	sizes := make([]int, 4)
+	for _, l := range labels {
+		sizes[l]++
+	}
+	for shard, size := range sizes {
+		if size < 250 || size > 251 {
+			t.Errorf("shard %d has %d vectors, want 250 or 251", shard, size)
+		}
+	}
+
+	if again := PartitionByProjection(vecs, 4, 7); !reflect.DeepEqual(again, labels) {
+		t.Error("same seed gave a different partition")
+	}
+	if other := PartitionByProjection(vecs, 4, 8); samePartition(other, labels) {
+		t.Error("different seeds gave the same partition")
+	}
+}
+
+func TestPartitionByProjectionEdgeCases(t *testing.T) {
+	if got := PartitionByProjection(nil, 3, 1); len(got) != 0 || got == nil {
+		t.Errorf("no vecs: got %v, want empty", got)
+	}
+	if got := PartitionByProjection([][]float64{{1}, {2}}, 5, 1); len(got) != 2 {
+		t.Errorf("more shards than vecs: got %v", got)
+	}
+	if PartitionByProjection([][]float64{{1}}, 0, 1) != nil {
+		t.Error("accepted zero shards")
+	}
+	if PartitionByProjection([][]float64{{1}, {1, 2}}, 2, 1) != nil {
+		t.Error("accepted vectors of differing dimension")
+	}
+}
@@ -0,0 +1,180 @@
+package kmeans
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// nearestCentroids returns the indexes of up to n centroids closest to vec
+// under the model metric, closest first.
+func (km *KMeans) nearestCentroids(vec []float64, n int) []int {
+	type scored struct {
+		index int
+		dist  float64
+	}
+	candidates := make([]scored, 0, len(km.centroids))
+	for i, c := range km.centroids {
+		if d, err := km.metric(c.vec, vec); err == nil {
+			candidates = append(candidates, scored{i, d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	indexes := make([]int, 0, n)
+	for _, c := range candidates[:n] {
+		indexes = append(indexes, c.index)
+	}
+	return indexes
+}
+
+// QueryCapped looks vec up in the probes centroids nearest to it and
+// returns up to globalK payloads overall, closest to vec under the model
+// metric first. Each probed centroid contributes its own top globalK
+// candidates, so a centroid's lower-ranked results can still beat another
+// centroid's best. The candidates are merged through a bounded heap, so
+// merging costs O(log globalK) per candidate rather than a sort of them
+// all. Payloads are not drained.
+func (km *KMeans) QueryCapped(vec []float64, probes, globalK int) []payloadContainer {
+	if probes <= 0 || globalK <= 0 {
+		return []payloadContainer{}
+	}
+	top := newTopK(globalK)
+	for _, index := range km.nearestCentroids(vec, probes) {
+		for _, p := range km.centroids[index].KNNLookup(vec, globalK, false) {
+			if d, err := km.metric(vec, p.Vec()); err == nil {
+				top.offer(p, d)
+			}
+		}
+	}
+	return top.sorted()
+}
+
+// QueryBestFirst collects the non-expired payloads of the probes centroids
+// nearest to vec and returns a generator yielding them one at a time,
+// closest to vec under metric first, along with their distance. A nil
+// metric means the model metric; either way, centroids are picked by the
+// model metric.
+//
+// Candidates are heap-ordered rather than sorted, so the first result is
+// ready after a linear pass and each later one costs O(log n); callers that
+// stop early never pay for a full sort. Payloads that can't be compared
+// with vec are skipped.
+//
+// The candidate set is fixed when QueryBestFirst returns, so the generator
+// may be drained after the model has changed. Payloads are not drained.
+func (km *KMeans) QueryBestFirst(
+	vec []float64,
+	probes int,
+	metric mathutils.Metric,
+) func() (payloadContainer, float64, bool) {
+	if metric == nil {
+		metric = km.metric
+	}
+	var h candidateHeap
+	if probes > 0 {
+		for _, index := range km.nearestCentroids(vec, probes) {
+			for _, p := range km.centroids[index].DataPoints {
+				if p.Expired() {
+					continue
+				}
+				if d, err := metric(vec, p.Vec()); err == nil {
+					h = append(h, candidate{p: p, dist: d, seq: len(h)})
+				}
+			}
+		}
+	}
+	heap.Init(&h)
+	return func() (payloadContainer, float64, bool) {
+		if h.Len() == 0 {
+			return nil, 0, false
+		}
+		c := heap.Pop(&h).(candidate)
+		return c.p, c.dist, true
+	}
+}
+
+// topK keeps the k best candidates offered to it, best meaning closest
+// with earlier offers winning ties, in O(log k) per offer and O(k) memory.
+// It is a heap with the worst kept candidate on top, so that a newcomer
+// only has to beat that one.
+type topK struct {
+	k   int
+	h   worstFirst
+	seq int
+}
+
+// worstFirst is a candidateHeap ordered worst first.
+type worstFirst struct {
+	candidateHeap
+}
+
+func (h worstFirst) Less(i, j int) bool {
+	return h.candidateHeap[j].before(h.candidateHeap[i])
+}
+
+// newTopK returns an empty topK keeping up to k candidates.
+func newTopK(k int) *topK {
+	return &topK{k: k, h: worstFirst{make(candidateHeap, 0, k)}}
+}
+
+// offer considers p, at distance dist, for the top k.
+func (t *topK) offer(p payloadContainer, dist float64) {
+	c := candidate{p: p, dist: dist, seq: t.seq}
+	t.seq++
+	if t.h.Len() < t.k {
+		heap.Push(&t.h, c)
+		return
+	}
+	if t.k > 0 && c.before(t.h.candidateHeap[0]) {
+		t.h.candidateHeap[0] = c
+		heap.Fix(&t.h, 0)
+	}
+}
+
+// sorted empties t and returns its payloads, best first.
+func (t *topK) sorted() []payloadContainer {
+	result := make([]payloadContainer, t.h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(&t.h).(candidate).p
+	}
+	return result
+}
+
+// candidate is a query result waiting in a candidateHeap. seq is its
+// collection order, which breaks distance ties so results are deterministic.
+type candidate struct {
+	p    payloadContainer
+	dist float64
+	seq  int
+}
+
+// before reports whether c ranks before other: it is closer, or as close
+// and collected earlier.
+func (c candidate) before(other candidate) bool {
+	if c.dist != other.dist {
+		return c.dist < other.dist
+	}
+	return c.seq < other.seq
+}
+
+// candidateHeap is a min-heap of candidates by distance; see container/heap.
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int           { return len(h) }
+func (h candidateHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h candidateHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+
+func (h *candidateHeap) Push(x any) { *h = append(*h, x.(candidate)) }
+
+func (h *candidateHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
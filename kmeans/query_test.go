@@ -0,0 +1,137 @@
+package kmeans
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// payloadVecs returns the vectors of payloads.
+func payloadVecs(payloads []payloadContainer) [][]float64 {
+	vecs := make([][]float64, len(payloads))
+	for i, p := range payloads {
+		vecs[i] = p.Vec()
+	}
+	return vecs
+}
+
+// newTestModel returns a Euclidean model made of the given centroids.
+func newTestModel(centroids ...*Centroid) *KMeans {
+	km := NewKMeans(NewKMeansArgs{})
+	km.centroids = centroids
+	return km
+}
+
+func TestKMeansQueryCapped(t *testing.T) {
+	km := newTestModel(
+		newTestCentroid(t, []float64{1.5}, []float64{0}, []float64{1}, []float64{2}, []float64{3}),
+		newTestCentroid(t, []float64{9}, []float64{6}, []float64{10}, []float64{11}),
+	)
+
+	// The second centroid's runner-up (10) is worse than the first
+	// centroid's runner-ups, so only its best result makes the cut.
+	got := payloadVecs(km.QueryCapped([]float64{5}, 2, 3))
+	if want := [][]float64{{6}, {3}, {2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("probes=2: got %v, want %v", got, want)
+	}
+
+	got = payloadVecs(km.QueryCapped([]float64{5}, 1, 3))
+	if want := [][]float64{{3}, {2}, {1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("probes=1: got %v, want %v", got, want)
+	}
+
+	if got := km.QueryCapped([]float64{5}, 2, 0); len(got) != 0 {
+		t.Errorf("globalK=0: got %v", got)
+	}
+}
+
+func TestKMeansQueryBestFirst(t *testing.T) {
+	km := newTestModel(
+		newTestCentroid(t, []float64{1.5}, []float64{0}, []float64{3}, []float64{1}, []float64{2}),
+		newTestCentroid(t, []float64{9}, []float64{11}, []float64{6}, []float64{10}),
+	)
+	km.centroids[0].AddPayload(&testPayload{vec: []float64{4}, expired: true})
+
+	next := km.QueryBestFirst([]float64{5}, 2, nil)
+	var got [][]float64
+	var dists []float64
+	for p, d, ok := next(); ok; p, d, ok = next() {
+		got = append(got, p.Vec())
+		dists = append(dists, d)
+	}
+	// 0 and 10 tie; the nearer centroid is probed first, so 0 wins.
+	want := [][]float64{{6}, {3}, {2}, {1}, {0}, {10}, {11}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if wantDists := []float64{1, 2, 3, 4, 5, 5, 6}; !reflect.DeepEqual(dists, wantDists) {
+		t.Errorf("got distances %v, want %v", dists, wantDists)
+	}
+
+	if _, _, ok := km.QueryBestFirst([]float64{5}, 0, nil)(); ok {
+		t.Error("probes=0 yielded a result")
+	}
+}
+
+// sortMerge is the concat-and-sort merge topK replaces, kept as a
+// reference.
+func sortMerge(candidates []candidate, k int) []payloadContainer {
+	sorted := append([]candidate(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].dist < sorted[j].dist
+	})
+	if len(sorted) > k {
+		sorted = sorted[:k]
+	}
+	result := make([]payloadContainer, len(sorted))
+	for i, c := range sorted {
+		result[i] = c.p
+	}
+	return result
+}
+
+// mergeCandidates returns sources*perSource candidates with distances
+// drawn from a small range, so there are plenty of ties.
+func mergeCandidates(sources, perSource int) []candidate {
+	rng := rand.New(rand.NewSource(1))
+	candidates := make([]candidate, 0, sources*perSource)
+	for i := 0; i < sources*perSource; i++ {
+		p := &testPayload{vec: []float64{float64(i)}}
+		candidates = append(candidates, candidate{p: p, dist: float64(rng.Intn(50))})
+	}
+	return candidates
+}
+
+func TestTopK(t *testing.T) {
+	candidates := mergeCandidates(20, 30)
+	for _, k := range []int{0, 1, 10, 599, 600, 1000} {
+		top := newTopK(k)
+		for _, c := range candidates {
+			top.offer(c.p, c.dist)
+		}
+		if got, want := top.sorted(), sortMerge(candidates, k); !reflect.DeepEqual(got, want) {
+			t.Errorf("k=%d: heap merge differs from sort merge", k)
+		}
+	}
+}
+
+func BenchmarkMergeTopK(b *testing.B) {
+	candidates := mergeCandidates(64, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		top := newTopK(10)
+		for _, c := range candidates {
+			top.offer(c.p, c.dist)
+		}
+		top.sorted()
+	}
+}
+
+func BenchmarkMergeSort(b *testing.B) {
+	candidates := mergeCandidates(64, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sortMerge(candidates, 10)
+	}
+}
@@ -0,0 +1,52 @@
+package kmeans
+
+import "math/rand"
+
+// reservoir is the sampling state of a centroid with a ReservoirCap.
+type reservoir struct {
+	cap  int
+	rng  *rand.Rand
+	seen int
+}
+
+// addSampled is AddPayload for a centroid with a reservoir, once p has
+// passed validation; see NewCentroidArgs.ReservoirCap.
+func (c *Centroid) addSampled(p payloadContainer) bool {
+	c.reservoir.seen++
+	if len(c.DataPoints) < c.reservoir.cap {
+		c.DataPoints = append(c.DataPoints, p)
+		c.sse += c.sqDistance(p)
+		c.sum.Add(p.Vec())
+		return true
+	}
+	if c.reservoir.rng.Float64()*float64(c.reservoir.seen) >= float64(c.reservoir.cap) {
+		return c.reject(p, RejectSampledOut)
+	}
+
+	victim := c.pickVictim()
+	c.untrack(c.DataPoints[victim])
+	c.DataPoints[victim] = p
+	c.sse += c.sqDistance(p)
+	return true
+}
+
+// pickVictim returns the index of a payload to evict, picked with
+// probability proportional to its similarity to the centroid vector.
+func (c *Centroid) pickVictim() int {
+	weights := make([]float64, len(c.DataPoints))
+	var total float64
+	for i, p := range c.DataPoints {
+		weights[i] = similarity(c.metric, c.vec, p.Vec())
+		total += weights[i]
+	}
+	if total == 0 {
+		return c.reservoir.rng.Intn(len(c.DataPoints))
+	}
+	r := c.reservoir.rng.Float64() * total
+	for i, w := range weights {
+		if r -= w; r < 0 {
+			return i
+		}
+	}
+	return len(weights) - 1
+}
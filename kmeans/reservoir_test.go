@@ -0,0 +1,91 @@
+package kmeans
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+func TestCentroidReservoir(t *testing.T) {
+	const capacity, n = 200, 20_000
+	var sampledOut int
+	c, ok := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		ReservoirCap:  capacity,
+		ReservoirRng:  rand.New(rand.NewSource(1)),
+		OnReject: func(_ payloadContainer, reason string) {
+			if reason == RejectSampledOut {
+				sampledOut++
+			}
+		},
+	})
+	if !ok {
+		t.Fatal("failed to create centroid")
+	}
+
+	// Uniform on [-1, 1], tagged with their position in the stream.
+	rng := rand.New(rand.NewSource(2))
+	order := map[payloadContainer]int{}
+	for i := 0; i < n; i++ {
+		p := &testPayload{vec: []float64{rng.Float64()*2 - 1}}
+		order[p] = i
+		if c.AddPayload(p) && c.LenDP() > capacity {
+			t.Fatalf("holding %d payloads, cap %d", c.LenDP(), capacity)
+		}
+	}
+	if c.LenDP() != capacity || sampledOut == 0 {
+		t.Fatalf("got LenDP %d and %d sampled out", c.LenDP(), sampledOut)
+	}
+
+	var sum float64
+	var neg, late, far int
+	for _, p := range c.DataPoints {
+		x := p.Vec()[0]
+		sum += x
+		if x < 0 {
+			neg++
+		}
+		if math.Abs(x) > 0.5 {
+			far++
+		}
+		if order[p] >= n/2 {
+			late++
+		}
+	}
+	if mean := sum / capacity; math.Abs(mean) > 0.15 {
+		t.Errorf("got sample mean %v, want near 0", mean)
+	}
+	if neg < capacity/3 || neg > 2*capacity/3 {
+		t.Errorf("%d of %d kept payloads are negative, want about half", neg, capacity)
+	}
+	if late < capacity/3 {
+		t.Errorf("only %d of %d kept payloads are from the second half of the stream", late, capacity)
+	}
+	// Eviction favours payloads near the centre, so the outer half of
+	// the range is at least as well represented as in the input.
+	if far < capacity/2 {
+		t.Errorf("only %d of %d kept payloads are far from the centre", far, capacity)
+	}
+	if math.Abs(c.TrackedSSE()-c.SSE()) > 1e-9 {
+		t.Errorf("tracked SSE %v drifted from %v", c.TrackedSSE(), c.SSE())
+	}
+}
+
+func TestCentroidReservoirInvalid(t *testing.T) {
+	_, ok := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		ReservoirCap:  -1,
+	})
+	if ok {
+		t.Error("accepted negative reservoir cap")
+	}
+}
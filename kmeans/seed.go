@@ -0,0 +1,203 @@
+package kmeans
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// SeedFunc picks k initial centroid vectors for Fit from vecs, which are
+// non-empty, non-nil and of equal dimension, with 1 <= k <= len(vecs).
+// Distance-based strategies measure with metric; randomized ones draw from
+// rng. The returned vectors are copied by Fit, so they may alias vecs.
+type SeedFunc func(vecs [][]float64, k int, metric mathutils.Metric, rng *rand.Rand) [][]float64
+
+// kMeansParallelRounds is the number of oversampling rounds SeedKMeansParallel
+// runs. A handful suffices in practice.
+const kMeansParallelRounds = 5
+
+// SeedRandom picks k distinct vectors from vecs uniformly at random. It is
+// the default SeedFunc.
+func SeedRandom(vecs [][]float64, k int, _ mathutils.Metric, rng *rand.Rand) [][]float64 {
+	seeds := make([][]float64, k)
+	for i, index := range rng.Perm(len(vecs))[:k] {
+		seeds[i] = vecs[index]
+	}
+	return seeds
+}
+
+// SeedFirstK picks the first k vectors. It is deterministic, so useful when
+// the input order is meaningful or for reproducing a fit exactly.
+func SeedFirstK(vecs [][]float64, k int, _ mathutils.Metric, _ *rand.Rand) [][]float64 {
+	return append([][]float64(nil), vecs[:k]...)
+}
+
+// SeedRandomInBounds draws k vectors uniformly from the bounding box of
+// vecs. The seeds needn't be near any data, so some clusters may start
+// empty.
+func SeedRandomInBounds(vecs [][]float64, k int, _ mathutils.Metric, rng *rand.Rand) [][]float64 {
+	lo := append([]float64(nil), vecs[0]...)
+	hi := append([]float64(nil), vecs[0]...)
+	for _, v := range vecs[1:] {
+		for j, x := range v {
+			lo[j], hi[j] = math.Min(lo[j], x), math.Max(hi[j], x)
+		}
+	}
+	seeds := make([][]float64, k)
+	for i := range seeds {
+		seeds[i] = make([]float64, len(lo))
+		for j := range lo {
+			seeds[i][j] = lo[j] + rng.Float64()*(hi[j]-lo[j])
+		}
+	}
+	return seeds
+}
+
+// SeedKMeansPP picks k vectors with k-means++: the first uniformly at
+// random, each next one with probability proportional to its squared
+// distance from the nearest seed so far. Spreading seeds out this way
+// avoids most of the poor local optima of random seeding.
+func SeedKMeansPP(vecs [][]float64, k int, metric mathutils.Metric, rng *rand.Rand) [][]float64 {
+	weights := make([]float64, len(vecs))
+	for i := range weights {
+		weights[i] = 1
+	}
+	return vecsAt(vecs, kMeansPP(vecs, weights, k, metric, rng))
+}
+
+// SeedKMeansParallel picks k vectors with k-means|| (scalable k-means++):
+// a few rounds each sample about 2k candidates at once, with probability
+// proportional to squared distance from the candidates so far, and the
+// candidates, weighted by how many vectors are nearest to each, are then
+// reduced to k with k-means++. It needs far fewer passes over the data
+// than k-means++ when k is large.
+func SeedKMeansParallel(vecs [][]float64, k int, metric mathutils.Metric, rng *rand.Rand) [][]float64 {
+	first := rng.Intn(len(vecs))
+	candidates := []int{first}
+	chosen := map[int]bool{first: true}
+	dist := make([]float64, len(vecs))
+	for i, v := range vecs {
+		dist[i] = sqDist(metric, v, vecs[first])
+	}
+
+	oversample := float64(2 * k)
+	for round := 0; round < kMeansParallelRounds; round++ {
+		var cost float64
+		for _, d := range dist {
+			cost += d
+		}
+		if cost == 0 {
+			break
+		}
+		var picked []int
+		for i, d := range dist {
+			if !chosen[i] && rng.Float64() < oversample*d/cost {
+				picked = append(picked, i)
+			}
+		}
+		for _, p := range picked {
+			candidates = append(candidates, p)
+			chosen[p] = true
+			for i, v := range vecs {
+				dist[i] = math.Min(dist[i], sqDist(metric, v, vecs[p]))
+			}
+		}
+	}
+	// Top up with random vectors if sampling came up short, as it does
+	// on data with fewer than k distinct vectors.
+	for _, i := range rng.Perm(len(vecs)) {
+		if len(candidates) >= k {
+			break
+		}
+		if !chosen[i] {
+			candidates = append(candidates, i)
+			chosen[i] = true
+		}
+	}
+
+	weights := make([]float64, len(candidates))
+	for _, v := range vecs {
+		best, bestDist := 0, math.Inf(1)
+		for j, c := range candidates {
+			if d := sqDist(metric, v, vecs[c]); d < bestDist {
+				best, bestDist = j, d
+			}
+		}
+		weights[best]++
+	}
+	reduced := kMeansPP(vecsAt(vecs, candidates), weights, k, metric, rng)
+	seeds := make([][]float64, k)
+	for i, j := range reduced {
+		seeds[i] = vecs[candidates[j]]
+	}
+	return seeds
+}
+
+// kMeansPP returns the indexes of k distinct vectors picked by weighted
+// k-means++: each pick has probability proportional to weight times squared
+// distance from the nearest earlier pick. Once every remaining vector is at
+// distance 0 (duplicates), picks fall back to the heaviest remaining ones.
+func kMeansPP(vecs [][]float64, weights []float64, k int, metric mathutils.Metric, rng *rand.Rand) []int {
+	picked := make([]int, 0, k)
+	taken := make([]bool, len(vecs))
+	dist := make([]float64, len(vecs))
+	for i := range dist {
+		dist[i] = 1 // Uniform over weights for the first pick.
+	}
+	for len(picked) < k {
+		var total float64
+		for i, d := range dist {
+			if !taken[i] {
+				total += weights[i] * d
+			}
+		}
+		next := -1
+		if total > 0 {
+			r := rng.Float64() * total
+			for i, d := range dist {
+				if taken[i] || weights[i]*d == 0 {
+					continue
+				}
+				next = i
+				if r -= weights[i] * d; r < 0 {
+					break
+				}
+			}
+		} else {
+			for i := range vecs {
+				if !taken[i] && (next == -1 || weights[i] > weights[next]) {
+					next = i
+				}
+			}
+		}
+		picked = append(picked, next)
+		taken[next] = true
+		for i, v := range vecs {
+			d := sqDist(metric, v, vecs[next])
+			if len(picked) == 1 || d < dist[i] {
+				dist[i] = d
+			}
+		}
+	}
+	return picked
+}
+
+// sqDist returns the squared distance between v1 and v2 under metric, or 0
+// if they can't be compared.
+func sqDist(metric mathutils.Metric, v1, v2 []float64) float64 {
+	d, err := metric(v1, v2)
+	if err != nil {
+		return 0
+	}
+	return d * d
+}
+
+// vecsAt returns the vectors of vecs at indexes.
+func vecsAt(vecs [][]float64, indexes []int) [][]float64 {
+	result := make([][]float64, len(indexes))
+	for i, index := range indexes {
+		result[i] = vecs[index]
+	}
+	return result
+}
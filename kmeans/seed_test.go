@@ -0,0 +1,104 @@
+package kmeans
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+func TestSeedFuncs(t *testing.T) {
+	vecs, labels := MakeBlobs(200, 4, 3, 0.5, rand.New(rand.NewSource(1)))
+	seeds := map[string]SeedFunc{
+		"SeedRandom":         SeedRandom,
+		"SeedFirstK":         SeedFirstK,
+		"SeedRandomInBounds": SeedRandomInBounds,
+		"SeedKMeansPP":       SeedKMeansPP,
+		"SeedKMeansParallel": SeedKMeansParallel,
+	}
+	members := map[string]bool{}
+	for _, v := range vecs {
+		members[fmt.Sprint(v)] = true
+	}
+
+	for name, seed := range seeds {
+		got := seed(vecs, 4, mathutils.EuclideanDistance, rand.New(rand.NewSource(1)))
+		if len(got) != 4 {
+			t.Errorf("%s: got %d seeds, want 4", name, len(got))
+			continue
+		}
+		distinct := map[string]bool{}
+		for _, v := range got {
+			if len(v) != 3 {
+				t.Errorf("%s: seed %v has dimension %d, want 3", name, v, len(v))
+			}
+			if name != "SeedRandomInBounds" && !members[fmt.Sprint(v)] {
+				t.Errorf("%s: seed %v is not one of the vectors", name, v)
+			}
+			distinct[fmt.Sprint(v)] = true
+		}
+		if len(distinct) != 4 {
+			t.Errorf("%s: got %d distinct seeds, want 4", name, len(distinct))
+		}
+
+		km := NewKMeans(NewKMeansArgs{})
+		if !km.Fit(vecs, FitArgs{K: 4, MaxIter: 100, Seed: seed}) {
+			t.Errorf("%s: fit failed", name)
+		}
+		if name == "SeedKMeansPP" || name == "SeedKMeansParallel" {
+			if ari := adjustedRandIndex(km.Assign(vecs), labels); ari < 0.99 {
+				t.Errorf("%s: got ARI %v, want >= 0.99", name, ari)
+			}
+		}
+	}
+}
+
+func TestSeedRandomInBounds(t *testing.T) {
+	vecs := [][]float64{{0, 10}, {2, 10}, {1, 12}}
+	for _, v := range SeedRandomInBounds(vecs, 3, nil, rand.New(rand.NewSource(1))) {
+		if v[0] < 0 || v[0] > 2 || v[1] < 10 || v[1] > 12 {
+			t.Errorf("seed %v outside bounds", v)
+		}
+	}
+}
+
+func TestSeedKMeansPPDuplicates(t *testing.T) {
+	// Only two distinct vectors: the third pick must still be a new
+	// index rather than a repeat.
+	vecs := [][]float64{{0}, {0}, {5}, {5}}
+	for name, seed := range map[string]SeedFunc{
+		"SeedKMeansPP":       SeedKMeansPP,
+		"SeedKMeansParallel": SeedKMeansParallel,
+	} {
+		got := seed(vecs, 3, mathutils.EuclideanDistance, rand.New(rand.NewSource(1)))
+		if len(got) != 3 {
+			t.Fatalf("%s: got %d seeds, want 3", name, len(got))
+		}
+		zeros := 0
+		for _, v := range got {
+			if v[0] == 0 {
+				zeros++
+			}
+		}
+		if zeros == 0 || zeros == 3 {
+			t.Errorf("%s: got %v, want both values", name, got)
+		}
+	}
+}
+
+func TestKMeansFitSeedInvalid(t *testing.T) {
+	bad := map[string]SeedFunc{
+		"too few": func(vecs [][]float64, k int, _ mathutils.Metric, _ *rand.Rand) [][]float64 {
+			return vecs[:k-1]
+		},
+		"wrong dimension": func(_ [][]float64, k int, _ mathutils.Metric, _ *rand.Rand) [][]float64 {
+			return make([][]float64, k)
+		},
+	}
+	for name, seed := range bad {
+		if NewKMeans(NewKMeansArgs{}).Fit(twoGroups, FitArgs{K: 2, MaxIter: 10, Seed: seed}) {
+			t.Errorf("%s: fit succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package kmeans
+
+// sinkBatchSize is the largest batch CentroidSink adds at once, and the
+// buffer size of its channel.
+const sinkBatchSize = 64
+
+// CentroidSink returns a channel through which producers can push vectors
+// into c. Each vector is wrapped by factory (nil wraps them in payloads
+// that never expire) and added with AddPayload, so rejections reach
+// NewCentroidArgs.OnReject as usual.
+//
+// A background goroutine collects vectors into batches of up to 64,
+// reserving room for a whole batch at once, and adds a batch as soon as
+// the channel has nothing more waiting. The channel buffers one batch, so
+// producers block once the goroutine falls behind. Close the channel when
+// done; the returned done channel is closed once every vector sent has been
+// added. c must not be used elsewhere until then.
+//
+// Returns nil channels if c is nil.
+func CentroidSink(
+	c *Centroid,
+	factory func(vec []float64) payloadContainer,
+) (chan<- []float64, <-chan struct{}) {
+	if c == nil {
+		return nil, nil
+	}
+	if factory == nil {
+		factory = func(vec []float64) payloadContainer { return &vecPayload{vec: vec} }
+	}
+	vecs := make(chan []float64, sinkBatchSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		batch := make([][]float64, 0, sinkBatchSize)
+		for vec := range vecs {
+			batch = append(batch[:0], vec)
+		fill:
+			for len(batch) < sinkBatchSize {
+				select {
+				case vec, ok := <-vecs:
+					if !ok {
+						break fill
+					}
+					batch = append(batch, vec)
+				default:
+					break fill
+				}
+			}
+			c.Reserve(len(batch))
+			for _, vec := range batch {
+				c.AddPayload(factory(vec))
+			}
+		}
+	}()
+	return vecs, done
+}
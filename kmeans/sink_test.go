@@ -0,0 +1,64 @@
+package kmeans
+
+import (
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+func TestCentroidSink(t *testing.T) {
+	var rejected int
+	c, _ := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		OnReject:      func(payloadContainer, string) { rejected++ },
+	})
+	sink, done := CentroidSink(c, nil)
+
+	const n = 1000
+	for i := 0; i < n; i++ {
+		sink <- []float64{float64(i)}
+	}
+	sink <- []float64{1, 2}
+	close(sink)
+	<-done
+
+	if c.LenDP() != n {
+		t.Errorf("got LenDP %d, want %d", c.LenDP(), n)
+	}
+	for i, p := range c.DataPoints {
+		if p.Vec()[0] != float64(i) {
+			t.Fatalf("payload %d has vec %v: out of order", i, p.Vec())
+		}
+	}
+	if rejected != 1 {
+		t.Errorf("got %d rejections, want 1", rejected)
+	}
+}
+
+func TestCentroidSinkFactory(t *testing.T) {
+	c := newTestCentroid(t, []float64{0})
+	sink, done := CentroidSink(c, func(vec []float64) payloadContainer {
+		return &testPayload{vec: vec, expired: vec[0] < 0}
+	})
+	sink <- []float64{1}
+	sink <- []float64{-1}
+	close(sink)
+	<-done
+
+	if c.LenDP() != 1 {
+		t.Errorf("got LenDP %d, want 1", c.LenDP())
+	}
+	if _, ok := c.DataPoints[0].(*testPayload); !ok {
+		t.Errorf("got payload of type %T, want *testPayload", c.DataPoints[0])
+	}
+}
+
+func TestCentroidSinkNil(t *testing.T) {
+	if sink, done := CentroidSink(nil, nil); sink != nil || done != nil {
+		t.Error("got channels for nil centroid")
+	}
+}
@@ -0,0 +1,148 @@
+package kmeans
+
+import "math"
+
+// SparseVec is a sparse vector: the values of its non-zero dimensions, by
+// dimension index.
+type SparseVec map[int]float64
+
+// SketchCentroid approximates the mean of sparse, non-negative count vectors
+// over a vocabulary too large to materialize, such as term counts in text.
+// Instead of payloads or a dense mean it keeps a count-min sketch of the
+// per-dimension sums plus a bounded set of candidate top dimensions, so its
+// memory is fixed by its arguments, not by the vocabulary.
+//
+// Estimates never undershoot. With width w and depth d, each overshoots the
+// true mean by at most e/w times the mean's total mass (its L1 norm), with
+// probability at least 1-exp(-d).
+type SketchCentroid struct {
+	table [][]float64
+	seeds []uint64
+	count int
+	// top holds the estimated sums of the candidate top dimensions.
+	top  map[int]float64
+	topK int
+}
+
+// NewSketchCentroidArgs is the argument set for NewSketchCentroid.
+type NewSketchCentroidArgs struct {
+	// Width is the number of counters per sketch row. Wider sketches are
+	// more accurate.
+	Width int
+	// Depth is the number of sketch rows. Deeper sketches are more likely
+	// to meet the error bound.
+	Depth int
+	// TopK is the number of dimensions Vec reconstructs.
+	TopK int
+}
+
+// NewSketchCentroid creates an empty SketchCentroid from args. Returns false
+// if any argument is less than 1.
+func NewSketchCentroid(args NewSketchCentroidArgs) (*SketchCentroid, bool) {
+	if args.Width < 1 || args.Depth < 1 || args.TopK < 1 {
+		return nil, false
+	}
+	s := &SketchCentroid{
+		table: make([][]float64, args.Depth),
+		seeds: make([]uint64, args.Depth),
+		top:   make(map[int]float64, args.TopK),
+		topK:  args.TopK,
+	}
+	for row := range s.table {
+		s.table[row] = make([]float64, args.Width)
+		s.seeds[row] = mix64(uint64(row) + 1)
+	}
+	return s, true
+}
+
+// mix64 is the splitmix64 finalizer, a cheap bijective bit mixer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// bucket returns the counter of dim in the given row.
+func (s *SketchCentroid) bucket(row, dim int) int {
+	return int(mix64(uint64(dim)^s.seeds[row]) % uint64(len(s.table[row])))
+}
+
+// sum returns the estimated sum of dim over all added vectors.
+func (s *SketchCentroid) sum(dim int) float64 {
+	est := math.Inf(1)
+	for row := range s.table {
+		est = math.Min(est, s.table[row][s.bucket(row, dim)])
+	}
+	return est
+}
+
+// Add adds vec to the centroid. Returns false, leaving the centroid
+// untouched, if vec is nil or has negative or NaN values, which the sketch
+// can't account for.
+func (s *SketchCentroid) Add(vec SparseVec) bool {
+	if vec == nil {
+		return false
+	}
+	for _, x := range vec {
+		if !(x >= 0) {
+			return false
+		}
+	}
+	for dim, x := range vec {
+		for row := range s.table {
+			s.table[row][s.bucket(row, dim)] += x
+		}
+	}
+	s.count++
+	for dim := range vec {
+		s.offerTop(dim)
+	}
+	return true
+}
+
+// offerTop makes dim a candidate top dimension if its estimated sum beats
+// the weakest candidate, evicting that one once there are TopK.
+func (s *SketchCentroid) offerTop(dim int) {
+	est := s.sum(dim)
+	if _, ok := s.top[dim]; ok || len(s.top) < s.topK {
+		s.top[dim] = est
+		return
+	}
+	weakest, weakestSum := 0, math.Inf(1)
+	for d, sum := range s.top {
+		if sum < weakestSum || sum == weakestSum && d > weakest {
+			weakest, weakestSum = d, sum
+		}
+	}
+	if est > weakestSum {
+		delete(s.top, weakest)
+		s.top[dim] = est
+	}
+}
+
+// Count returns the number of vectors added.
+func (s *SketchCentroid) Count() int {
+	return s.count
+}
+
+// Estimate returns the estimated mean value of dim over the added vectors,
+// or 0 if there are none.
+func (s *SketchCentroid) Estimate(dim int) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum(dim) / float64(s.count)
+}
+
+// Vec reconstructs the approximate mean over the candidate top dimensions:
+// up to TopK dimensions with the largest estimated means. Every other
+// dimension is treated as zero.
+func (s *SketchCentroid) Vec() SparseVec {
+	vec := make(SparseVec, len(s.top))
+	for dim := range s.top {
+		vec[dim] = s.Estimate(dim)
+	}
+	return vec
+}
@@ -0,0 +1,92 @@
+package kmeans
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// zipfSparseVecs returns n sparse count vectors over a vocabulary of vocab
+// dimensions, each with about nnz Zipf-distributed dimensions, so a few
+// dimensions dominate as in text.
+func zipfSparseVecs(n, nnz int, vocab uint64, seed int64) []SparseVec {
+	rng := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rng, 1.2, 1, vocab-1)
+	vecs := make([]SparseVec, n)
+	for i := range vecs {
+		vecs[i] = SparseVec{}
+		for j := 0; j < nnz; j++ {
+			vecs[i][int(zipf.Uint64())]++
+		}
+	}
+	return vecs
+}
+
+func TestSketchCentroid(t *testing.T) {
+	const width, topK = 2000, 10
+	s, ok := NewSketchCentroid(NewSketchCentroidArgs{Width: width, Depth: 5, TopK: topK})
+	if !ok {
+		t.Fatal("failed to create sketch centroid")
+	}
+	vecs := zipfSparseVecs(500, 30, 1_000_000, 1)
+
+	exact := SparseVec{}
+	for _, v := range vecs {
+		if !s.Add(v) {
+			t.Fatalf("rejected %v", v)
+		}
+		for dim, x := range v {
+			exact[dim] += x / float64(len(vecs))
+		}
+	}
+	if s.Count() != len(vecs) {
+		t.Errorf("got count %d, want %d", s.Count(), len(vecs))
+	}
+
+	var mass float64
+	for _, x := range exact {
+		mass += x
+	}
+	bound := math.E / width * mass
+	for dim, want := range exact {
+		if got := s.Estimate(dim); got < want-1e-9 || got > want+bound {
+			t.Errorf("dim %d: got %v, want %v within +%v", dim, got, want, bound)
+		}
+	}
+
+	dims := make([]int, 0, len(exact))
+	for dim := range exact {
+		dims = append(dims, dim)
+	}
+	sort.Slice(dims, func(i, j int) bool { return exact[dims[i]] > exact[dims[j]] })
+	vec := s.Vec()
+	if len(vec) != topK {
+		t.Fatalf("got %d dims, want %d", len(vec), topK)
+	}
+	for _, dim := range dims[:5] {
+		if _, ok := vec[dim]; !ok {
+			t.Errorf("heavy dim %d (mean %v) missing from %v", dim, exact[dim], vec)
+		}
+	}
+}
+
+func TestSketchCentroidInvalid(t *testing.T) {
+	for _, args := range []NewSketchCentroidArgs{
+		{Width: 0, Depth: 1, TopK: 1},
+		{Width: 1, Depth: 0, TopK: 1},
+		{Width: 1, Depth: 1, TopK: 0},
+	} {
+		if _, ok := NewSketchCentroid(args); ok {
+			t.Errorf("%+v: expected failure", args)
+		}
+	}
+
+	s, _ := NewSketchCentroid(NewSketchCentroidArgs{Width: 10, Depth: 2, TopK: 2})
+	if s.Add(nil) || s.Add(SparseVec{1: 1, 2: -1}) || s.Add(SparseVec{1: math.NaN()}) {
+		t.Error("accepted invalid vector")
+	}
+	if s.Count() != 0 || s.Estimate(1) != 0 || len(s.Vec()) != 0 {
+		t.Error("rejected vector changed the centroid")
+	}
+}
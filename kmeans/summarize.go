@@ -0,0 +1,48 @@
+package kmeans
+
+import "github.com/crunchypi/net-means/mathutils"
+
+// Summarize returns a single centroid standing in for all of centroids,
+// e.g. for coarse-level indexing over many fine centroids. Its vector is the
+// mean of the input vectors weighted by their number of non-expired payloads
+// (or unweighted if none have any), and it holds the union of their
+// non-expired payloads. The inputs are left untouched and the summary is
+// configured like the first of them, except that it has no ReservoirCap or
+// OnReject, so that it holds every payload and reports nothing. Returns nil
+// if centroids is empty, holds a nil centroid, or if the centroids differ
+// in dimension.
+func Summarize(centroids []*Centroid) *Centroid {
+	if len(centroids) == 0 {
+		return nil
+	}
+	var weighted, unweighted mathutils.RunningSum
+	total := 0
+	for _, c := range centroids {
+		if c == nil || unweighted.Add(c.vec) != nil {
+			return nil
+		}
+		vec := c.VecCopy()
+		count := c.lenLive()
+		mathutils.Scale(vec, float64(count))
+		weighted.Add(vec)
+		total += count
+	}
+
+	var vec []float64
+	if total == 0 {
+		vec, _ = unweighted.Mean()
+	} else {
+		vec = weighted.Sum()
+		mathutils.Scale(vec, 1/float64(total))
+	}
+
+	args := centroids[0].internalArgs(vec)
+	args.InitCap = total
+	summary, _ := NewCentroid(args)
+	for _, c := range centroids {
+		for _, p := range c.DataPoints {
+			summary.AddPayload(p)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,78 @@
+package kmeans
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+func TestSummarize(t *testing.T) {
+	a := newTestCentroid(t, []float64{0, 0}, []float64{1, 1})
+	b := newTestCentroid(t, []float64{4, 0}, []float64{4, 1}, []float64{4, -1}, []float64{5, 0})
+	c := newTestCentroid(t, []float64{0, 8})
+
+	summary := Summarize([]*Centroid{a, b, c})
+	if summary == nil {
+		t.Fatal("unexpected nil summary")
+	}
+	if want := []float64{3, 0}; !reflect.DeepEqual(summary.Vec(), want) {
+		t.Errorf("got vec %v, want %v", summary.Vec(), want)
+	}
+	if summary.LenDP() != 4 {
+		t.Errorf("got LenDP %d, want 4", summary.LenDP())
+	}
+	if a.LenDP() != 1 || b.LenDP() != 3 {
+		t.Error("inputs were modified")
+	}
+}
+
+func TestSummarizeNoPayloads(t *testing.T) {
+	a := newTestCentroid(t, []float64{0, 0})
+	b := newTestCentroid(t, []float64{2, 4})
+	summary := Summarize([]*Centroid{a, b})
+	if want := []float64{1, 2}; !reflect.DeepEqual(summary.Vec(), want) {
+		t.Errorf("got vec %v, want %v", summary.Vec(), want)
+	}
+}
+
+func TestSummarizeInvalid(t *testing.T) {
+	if Summarize(nil) != nil {
+		t.Error("summarized nothing")
+	}
+	a := newTestCentroid(t, []float64{0, 0})
+	b := newTestCentroid(t, []float64{0})
+	if Summarize([]*Centroid{a, b}) != nil {
+		t.Error("summarized mismatched dimensions")
+	}
+	if Summarize([]*Centroid{a, nil}) != nil {
+		t.Error("summarized nil centroid")
+	}
+}
+
+func TestSummarizeReservoir(t *testing.T) {
+	var rejected int
+	capped := make([]*Centroid, 3)
+	for i := range capped {
+		capped[i], _ = NewCentroid(NewCentroidArgs{
+			InitVec:       []float64{float64(i)},
+			KNNSearchFunc: searchutils.KNNEuc,
+			KFNSearchFunc: searchutils.KFNEuc,
+			Metric:        mathutils.EuclideanDistance,
+			ReservoirCap:  2,
+			OnReject:      func(payloadContainer, string) { rejected++ },
+		})
+		for j := 0; j < 2; j++ {
+			capped[i].AddPayload(&testPayload{vec: []float64{float64(i)}})
+		}
+	}
+
+	summary := Summarize(capped)
+	if summary.LenDP() != 6 {
+		t.Errorf("got LenDP %d, want 6", summary.LenDP())
+	}
+	if rejected != 0 {
+		t.Errorf("summary rejected %d payloads", rejected)
+	}
+}
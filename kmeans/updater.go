@@ -0,0 +1,185 @@
+package kmeans
+
+import (
+	"math"
+	"sort"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// Updater is a strategy for moving a centroid vector to represent the
+// centroid's payloads; see NewCentroidArgs.Updater. Update computes the new
+// vector and writes it into c; updaters outside this package copy it into
+// the slice returned by c.Vec, which aliases the vector. It returns false,
+// leaving the vector unchanged, if it can't compute one, e.g. because c has
+// no non-expired payloads.
+type Updater interface {
+	Update(c *Centroid) bool
+}
+
+// liveVecs returns the vectors of the non-expired payloads of c.
+func (c *Centroid) liveVecs() [][]float64 {
+	vecs := make([][]float64, 0, len(c.DataPoints))
+	for _, p := range c.DataPoints {
+		if !p.Expired() {
+			vecs = append(vecs, p.Vec())
+		}
+	}
+	return vecs
+}
+
+// MeanUpdater moves a centroid to the mean of its non-expired payloads. It
+// is the default Updater.
+type MeanUpdater struct{}
+
+func (MeanUpdater) Update(c *Centroid) bool {
+	mean, ok := mathutils.VecMean(c.payloadVecGenerator())
+	if !ok {
+		return false
+	}
+	c.vec = mean
+	return true
+}
+
+// WeightedMeanUpdater moves a centroid to the mean of its non-expired
+// payloads weighted by Weight, e.g. a confidence score carried by the
+// payloads. Weights must not be negative. It fails if Weight is nil or the
+// weights sum to zero.
+type WeightedMeanUpdater struct {
+	Weight func(p payloadContainer) float64
+}
+
+func (u WeightedMeanUpdater) Update(c *Centroid) bool {
+	if u.Weight == nil {
+		return false
+	}
+	weights := make([]float64, len(c.DataPoints))
+	for i, p := range c.DataPoints {
+		weights[i] = u.Weight(p)
+	}
+	mean, ok := mathutils.WeightedVecMean(c.payloadVecGenerator(), floatGenerator(weights))
+	if !ok {
+		return false
+	}
+	c.vec = mean
+	return true
+}
+
+// MedianUpdater moves a centroid to the element-wise median of its
+// non-expired payloads, which ignores outliers entirely as long as they are
+// fewer than half. With an even count, the median of each element is the
+// mean of the middle two.
+type MedianUpdater struct{}
+
+func (MedianUpdater) Update(c *Centroid) bool {
+	return trimmedMean(c, -1)
+}
+
+// TrimmedMeanUpdater moves a centroid to the element-wise trimmed mean of
+// its non-expired payloads: in each dimension, the lowest and the highest
+// Fraction of the values are dropped and the rest averaged. Fraction is in
+// [0, 0.5); 0 gives the plain mean. It fails for other fractions.
+type TrimmedMeanUpdater struct {
+	Fraction float64
+}
+
+func (u TrimmedMeanUpdater) Update(c *Centroid) bool {
+	if !(u.Fraction >= 0 && u.Fraction < 0.5) {
+		return false
+	}
+	return trimmedMean(c, u.Fraction)
+}
+
+// trimmedMean moves c to the element-wise trimmed mean of its non-expired
+// payloads, dropping the given fraction at both ends, or to the median if
+// fraction is negative.
+func trimmedMean(c *Centroid, fraction float64) bool {
+	vecs := c.liveVecs()
+	if len(vecs) == 0 {
+		return false
+	}
+	n := len(vecs)
+	lo, hi := int(fraction*float64(n)), n-int(fraction*float64(n))
+	if fraction < 0 {
+		lo, hi = (n-1)/2, n/2+1
+	}
+
+	vec := make([]float64, len(c.vec))
+	column := make([]float64, n)
+	for d := range vec {
+		for i, v := range vecs {
+			column[i] = v[d]
+		}
+		sort.Float64s(column)
+		var sum float64
+		for _, x := range column[lo:hi] {
+			sum += x
+		}
+		vec[d] = sum / float64(hi-lo)
+	}
+	c.vec = vec
+	return true
+}
+
+// defaultHuberIterations is the number of reweighting rounds HuberUpdater
+// runs if not told otherwise.
+const defaultHuberIterations = 10
+
+// HuberUpdater moves a centroid to the Huber estimate of the location of its
+// non-expired payloads: payloads within Delta of the estimate (under the
+// centroid Metric) count fully, as in the mean, while those further away
+// count with weight Delta/distance, which bounds the pull of outliers. The
+// estimate starts at the element-wise median and is refined by Iterations
+// rounds of reweighting (10 if not positive). It fails unless Delta is
+// positive.
+type HuberUpdater struct {
+	Delta      float64
+	Iterations int
+}
+
+func (u HuberUpdater) Update(c *Centroid) bool {
+	if !(u.Delta > 0) {
+		return false
+	}
+	iterations := u.Iterations
+	if iterations <= 0 {
+		iterations = defaultHuberIterations
+	}
+	vecs := c.liveVecs()
+	old := c.vec
+	if !trimmedMean(c, -1) {
+		return false
+	}
+
+	weights := make([]float64, len(vecs))
+	for iter := 0; iter < iterations; iter++ {
+		for i, v := range vecs {
+			weights[i] = 1
+			if d := c.distance(v); d > u.Delta {
+				weights[i] = u.Delta / d
+			} else if math.IsNaN(d) {
+				weights[i] = 0
+			}
+		}
+		estimate, ok := mathutils.WeightedVecMean(mathutils.VecGenerator(vecs), floatGenerator(weights))
+		if !ok {
+			c.vec = old
+			return false
+		}
+		c.vec = estimate
+	}
+	return true
+}
+
+// floatGenerator returns a generator over xs, in the shape of
+// mathutils.WeightedVecMean's weights.
+func floatGenerator(xs []float64) func() (float64, bool) {
+	i := 0
+	return func() (float64, bool) {
+		if i >= len(xs) {
+			return 0, false
+		}
+		i++
+		return xs[i-1], true
+	}
+}
@@ -0,0 +1,93 @@
+package kmeans
+
+import (
+	"math"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+	"github.com/crunchypi/net-means/searchutils"
+)
+
+// outlierCentroid returns a Euclidean centroid using u, holding five
+// payloads around (0.5, 0.5) and one planted outlier at (100, 100).
+func outlierCentroid(t *testing.T, u Updater) *Centroid {
+	t.Helper()
+	c, ok := NewCentroid(NewCentroidArgs{
+		InitVec:       []float64{0, 0},
+		KNNSearchFunc: searchutils.KNNEuc,
+		KFNSearchFunc: searchutils.KFNEuc,
+		Metric:        mathutils.EuclideanDistance,
+		Updater:       u,
+	})
+	if !ok {
+		t.Fatal("failed to create centroid")
+	}
+	for _, v := range [][]float64{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0.5, 0.5}, {100, 100}} {
+		c.AddPayload(&testPayload{vec: v})
+	}
+	return c
+}
+
+func TestUpdaters(t *testing.T) {
+	outlierWeight := func(p payloadContainer) float64 {
+		if p.Vec()[0] == 100 {
+			return 0
+		}
+		return 1
+	}
+	tests := []struct {
+		name    string
+		updater Updater
+		want    float64
+		tol     float64
+	}{
+		{"default", nil, 102.5 / 6, 1e-12},
+		{"mean", MeanUpdater{}, 102.5 / 6, 1e-12},
+		{"weighted mean", WeightedMeanUpdater{Weight: outlierWeight}, 0.5, 1e-12},
+		// Sorted: 0 0 0.5 1 1 100.
+		{"median", MedianUpdater{}, 0.75, 1e-12},
+		{"trimmed", TrimmedMeanUpdater{Fraction: 0.2}, 0.625, 1e-12},
+		// The outlier's pull is bounded to about Delta/n.
+		{"huber", HuberUpdater{Delta: 1}, 0.5, 0.25},
+	}
+	for _, tt := range tests {
+		c := outlierCentroid(t, tt.updater)
+		if !c.MoveVector() {
+			t.Fatalf("%s: MoveVector failed", tt.name)
+		}
+		for d, x := range c.Vec() {
+			if math.Abs(x-tt.want) > tt.tol {
+				t.Errorf("%s: element %d is %v, want %v", tt.name, d, x, tt.want)
+			}
+		}
+		if got, want := c.TrackedSSE(), c.SSE(); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: tracked SSE %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestUpdatersInvalid(t *testing.T) {
+	updaters := map[string]Updater{
+		"nil weight":        WeightedMeanUpdater{},
+		"negative fraction": TrimmedMeanUpdater{Fraction: -0.1},
+		"half fraction":     TrimmedMeanUpdater{Fraction: 0.5},
+		"zero delta":        HuberUpdater{},
+	}
+	for name, u := range updaters {
+		c := outlierCentroid(t, u)
+		if c.MoveVector() {
+			t.Errorf("%s: MoveVector succeeded", name)
+		}
+		if got := c.Vec(); got[0] != 0 || got[1] != 0 {
+			t.Errorf("%s: vector moved to %v", name, got)
+		}
+	}
+
+	for _, u := range []Updater{MedianUpdater{}, HuberUpdater{Delta: 1}} {
+		c := newTestCentroid(t, []float64{1})
+		c.updater = u
+		if c.MoveVector() {
+			t.Errorf("%T: moved an empty centroid", u)
+		}
+	}
+}
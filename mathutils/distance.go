@@ -0,0 +1,258 @@
+package mathutils
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrNilVec is returned when one of the vectors given to a distance
+	// or similarity function is nil.
+	ErrNilVec = errors.New("mathutils: nil vector")
+	// ErrDimMismatch is returned when two vectors differ in length.
+	ErrDimMismatch = errors.New("mathutils: vector length mismatch")
+	// ErrInvalidParam is returned when a parameter of a distance function,
+	// such as the order of MinkowskiDistance, is out of range.
+	ErrInvalidParam = errors.New("mathutils: invalid parameter")
+)
+
+// checkPair validates that v1 and v2 are non-nil and of equal length.
+func checkPair(v1, v2 []float64) error {
+	if v1 == nil || v2 == nil {
+		return ErrNilVec
+	}
+	if len(v1) != len(v2) {
+		return ErrDimMismatch
+	}
+	return nil
+}
+
+// EuclideanDistance returns the L2 distance between v1 and v2. Returns an
+// error if either vector is nil or if they differ in length.
+func EuclideanDistance(v1, v2 []float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	var sum float64
+	for i := range v1 {
+		d := v1[i] - v2[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum), nil
+}
+
+// WeightedEuclideanDistance returns the Euclidean distance between v1 and v2
+// with each squared difference scaled by the weight of its dimension:
+// sqrt(sum w[i]*(v1[i]-v2[i])^2). This weighs dimensions without rescaling
+// the data itself. Returns an error if any of the vectors is nil, if they
+// differ in length, or if a weight is negative.
+func WeightedEuclideanDistance(v1, v2, weights []float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	if err := checkPair(v1, weights); err != nil {
+		return 0, err
+	}
+	var sum float64
+	for i := range v1 {
+		if !(weights[i] >= 0) {
+			return 0, ErrInvalidParam
+		}
+		d := v1[i] - v2[i]
+		sum += weights[i] * d * d
+	}
+	return math.Sqrt(sum), nil
+}
+
+// SquaredEuclideanDistance returns the squared L2 distance between v1 and
+// v2. It orders vectors the same way as EuclideanDistance but skips the
+// square root, so it is the cheaper choice when only ranking matters.
+// Returns an error if either vector is nil or if they differ in length.
+func SquaredEuclideanDistance(v1, v2 []float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	var sum float64
+	for i := range v1 {
+		d := v1[i] - v2[i]
+		sum += d * d
+	}
+	return sum, nil
+}
+
+// ManhattanDistance returns the L1 distance between v1 and v2: the sum of
+// their absolute differences. It is less dominated by single large
+// differences than EuclideanDistance. Returns an error if either vector is
+// nil or if they differ in length.
+func ManhattanDistance(v1, v2 []float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	var sum float64
+	for i := range v1 {
+		sum += math.Abs(v1[i] - v2[i])
+	}
+	return sum, nil
+}
+
+// ChebyshevDistance returns the L-infinity distance between v1 and v2: the
+// largest absolute difference in any one dimension. Empty vectors are at
+// distance 0. Returns an error if either vector is nil or if they differ in
+// length.
+func ChebyshevDistance(v1, v2 []float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	var dist float64
+	for i := range v1 {
+		dist = math.Max(dist, math.Abs(v1[i]-v2[i]))
+	}
+	return dist, nil
+}
+
+// MinkowskiDistance returns the distance of order p between v1 and v2:
+// (sum |v1[i]-v2[i]|^p)^(1/p). p=1 gives ManhattanDistance and p=2 gives
+// EuclideanDistance, so sweeping p moves between the two. Returns an error
+// if either vector is nil, if they differ in length, or if p is not
+// positive.
+func MinkowskiDistance(v1, v2 []float64, p float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	if !(p > 0) {
+		return 0, ErrInvalidParam
+	}
+	var sum float64
+	for i := range v1 {
+		sum += math.Pow(math.Abs(v1[i]-v2[i]), p)
+	}
+	return math.Pow(sum, 1/p), nil
+}
+
+// epsilon is the floor applied to norms that are divided by; see
+// SetEpsilon.
+var epsilon = 1e-12
+
+// SetEpsilon sets the floor applied to norms before dividing by them, so
+// that near-zero vectors give small, finite results instead of unstable
+// ones. Negative values are treated as 0, which disables the floor for all
+// but zero norms. The default is 1e-12. Like SetDefaultMetric, it is not
+// safe to call concurrently with the functions it affects: set it during
+// initialization.
+func SetEpsilon(eps float64) {
+	epsilon = math.Max(eps, 0)
+}
+
+// floorNorm returns n, raised to epsilon if it is below.
+func floorNorm(n float64) float64 {
+	return math.Max(n, epsilon)
+}
+
+// CosineSimilarity returns the cosine of the angle between v1 and v2, in
+// [-1, 1]. The similarity is 0 if either vector has zero norm; norms below
+// the epsilon floor (see SetEpsilon) are raised to it. Vectors with
+// elements so large that their norms or dot product overflow are scaled
+// down first, so they still give a finite result. Returns an error if
+// either vector is nil or if they differ in length.
+func CosineSimilarity(v1, v2 []float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	n1, n2 := Norm(v1), Norm(v2)
+	dot, _ := DotProduct(v1, v2)
+	if math.IsInf(n1, 0) || math.IsInf(n2, 0) || math.IsInf(dot, 0) {
+		return cosineScaled(v1, v2), nil
+	}
+	if n1 == 0 || n2 == 0 {
+		return 0, nil
+	}
+	return dot / floorNorm(n1) / floorNorm(n2), nil
+}
+
+// cosineScaled is CosineSimilarity computed over v1 and v2 scaled down by
+// their largest elements (see scaledNorm), which is slower but can't
+// overflow.
+func cosineScaled(v1, v2 []float64) float64 {
+	s1, n1 := scaledNorm(v1)
+	s2, n2 := scaledNorm(v2)
+	if s1 == 0 || s2 == 0 {
+		return 0
+	}
+	// n1 and n2 are norms of the scaled vectors, so the floor is scaled
+	// too.
+	if s1*n1 < epsilon {
+		n1 = epsilon / s1
+	}
+	if s2*n2 < epsilon {
+		n2 = epsilon / s2
+	}
+	var dot float64
+	for i := range v1 {
+		dot += (v1[i] / s1) * (v2[i] / s2)
+	}
+	return dot / n1 / n2
+}
+
+// CosineDistance is one minus CosineSimilarity, in [0, 2]. It turns the
+// similarity into a Metric, where smaller means closer, so cosine can be
+// used wherever a distance is expected. Returns an error if either vector
+// is nil or if they differ in length.
+func CosineDistance(v1, v2 []float64) (float64, error) {
+	sim, err := CosineSimilarity(v1, v2)
+	if err != nil {
+		return 0, err
+	}
+	return 1 - sim, nil
+}
+
+// CosineSimilarityNormalized is CosineSimilarity for vectors already known
+// to be of unit length, such as those held by centroids with
+// AutoNormalize: it returns their dot product, skipping the norms. The
+// precondition is not checked; for other vectors the result is not a
+// cosine. Returns an error if either vector is nil or if they differ in
+// length.
+func CosineSimilarityNormalized(v1, v2 []float64) (float64, error) {
+	return DotProduct(v1, v2)
+}
+
+// DotProduct returns the dot product of v1 and v2. Returns an error if
+// either vector is nil or if they differ in length.
+func DotProduct(v1, v2 []float64) (float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return 0, err
+	}
+	var dot float64
+	for i := range v1 {
+		dot += v1[i] * v2[i]
+	}
+	return dot, nil
+}
+
+// Norm returns the L2 norm (Euclidean length) of vec. Elements beyond about
+// 1e154 in magnitude overflow their square and make it Inf.
+func Norm(vec []float64) float64 {
+	var sum float64
+	for _, x := range vec {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// scaledNorm returns the largest magnitude among the elements of vec, and
+// the norm of vec divided by it, like math.Hypot does for two elements.
+// Their product is Norm(vec), but neither overflows for finite elements.
+// Both are 0 for zero vectors.
+func scaledNorm(vec []float64) (scale, n float64) {
+	for _, x := range vec {
+		scale = math.Max(scale, math.Abs(x))
+	}
+	if scale == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, x := range vec {
+		y := x / scale
+		sum += y * y
+	}
+	return scale, math.Sqrt(sum)
+}
@@ -0,0 +1,386 @@
+package mathutils
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomUnitVecs returns n random unit vectors of dimension dim.
+func randomUnitVecs(n, dim int, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	vecs := make([][]float64, n)
+	for i := range vecs {
+		vecs[i] = make([]float64, dim)
+		for j := range vecs[i] {
+			vecs[i][j] = rng.NormFloat64()
+		}
+		Normalize(vecs[i])
+	}
+	return vecs
+}
+
+func TestCosineSimilarityNormalized(t *testing.T) {
+	vecs := randomUnitVecs(20, 8, 1)
+	for i := 1; i < len(vecs); i++ {
+		want, _ := CosineSimilarity(vecs[i-1], vecs[i])
+		got, err := CosineSimilarityNormalized(vecs[i-1], vecs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-want) > 1e-12 {
+			t.Errorf("pair %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	if _, err := CosineSimilarityNormalized([]float64{1}, []float64{1, 0}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := CosineSimilarityNormalized(nil, []float64{1}); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func benchmarkCosine(b *testing.B, sim func(v1, v2 []float64) (float64, error)) {
+	vecs := randomUnitVecs(2, 256, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sim(vecs[0], vecs[1])
+	}
+}
+
+func BenchmarkCosineSimilarity(b *testing.B) { benchmarkCosine(b, CosineSimilarity) }
+func BenchmarkCosineSimilarityNormalized(b *testing.B) {
+	benchmarkCosine(b, CosineSimilarityNormalized)
+}
+
+func TestCosineSimilarityNearZero(t *testing.T) {
+	t.Cleanup(func() { SetEpsilon(1e-12) })
+	tiny := []float64{1e-160, -1e-160}
+	other := []float64{1e-160, 1e-160}
+
+	for _, eps := range []float64{1e-12, 0} {
+		SetEpsilon(eps)
+		for _, pair := range [][2][]float64{{tiny, other}, {tiny, tiny}, {tiny, {0, 0}}} {
+			got, err := CosineSimilarity(pair[0], pair[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.IsNaN(got) || math.IsInf(got, 0) || got < -1 || got > 1 {
+				t.Errorf("eps=%v: %v vs %v gave %v", eps, pair[0], pair[1], got)
+			}
+		}
+	}
+
+	SetEpsilon(1e-12)
+	if got, _ := CosineSimilarity(tiny, tiny); got > 1e-100 {
+		t.Errorf("got %v for vectors below the floor, want ~0", got)
+	}
+	if got, _ := CosineSimilarity([]float64{3, 4}, []float64{3, 4}); math.Abs(got-1) > 1e-15 {
+		t.Errorf("got %v for ordinary vectors, want 1", got)
+	}
+}
+
+func TestEuclideanDistance(t *testing.T) {
+	tests := []struct {
+		v1, v2 []float64
+		want   float64
+	}{
+		{[]float64{0, 0}, []float64{3, 4}, 5},
+		// Summing per-element roots would give the L1 distance, 3.
+		{[]float64{1, 1, 1}, []float64{0, 0, 0}, math.Sqrt(3)},
+		{[]float64{-2}, []float64{2}, 4},
+		{[]float64{}, []float64{}, 0},
+	}
+	for _, tt := range tests {
+		got, err := EuclideanDistance(tt.v1, tt.v2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v to %v: got %v, want %v", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+
+	if _, err := EuclideanDistance([]float64{1}, []float64{1, 2}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := EuclideanDistance([]float64{1}, nil); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func TestSquaredEuclideanDistance(t *testing.T) {
+	vecs := randomUnitVecs(10, 5, 2)
+	for i := 1; i < len(vecs); i++ {
+		d, _ := EuclideanDistance(vecs[i-1], vecs[i])
+		got, err := SquaredEuclideanDistance(vecs[i-1], vecs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-d*d) > 1e-12 {
+			t.Errorf("pair %d: got %v, want %v", i, got, d*d)
+		}
+	}
+	if got, _ := SquaredEuclideanDistance([]float64{0, 0}, []float64{3, 4}); got != 25 {
+		t.Errorf("got %v, want 25", got)
+	}
+
+	if _, err := SquaredEuclideanDistance([]float64{1}, []float64{1, 2}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := SquaredEuclideanDistance(nil, []float64{1}); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func benchmarkDistance(b *testing.B, dist func(v1, v2 []float64) (float64, error)) {
+	vecs := randomUnitVecs(2, 256, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dist(vecs[0], vecs[1])
+	}
+}
+
+func BenchmarkEuclideanDistance(b *testing.B) { benchmarkDistance(b, EuclideanDistance) }
+func BenchmarkSquaredEuclideanDistance(b *testing.B) {
+	benchmarkDistance(b, SquaredEuclideanDistance)
+}
+
+func TestManhattanDistance(t *testing.T) {
+	tests := []struct {
+		v1, v2 []float64
+		want   float64
+	}{
+		{[]float64{0, 0}, []float64{3, 4}, 7},
+		{[]float64{1, -2, 3}, []float64{-1, 2, 3}, 6},
+		{[]float64{}, []float64{}, 0},
+	}
+	for _, tt := range tests {
+		got, err := ManhattanDistance(tt.v1, tt.v2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v to %v: got %v, want %v", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+
+	if _, err := ManhattanDistance([]float64{1}, []float64{1, 2}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := ManhattanDistance(nil, []float64{1}); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func TestMinkowskiDistance(t *testing.T) {
+	vecs := randomUnitVecs(10, 6, 3)
+	for i := 1; i < len(vecs); i++ {
+		v1, v2 := vecs[i-1], vecs[i]
+		for _, tt := range []struct {
+			p    float64
+			want func(v1, v2 []float64) (float64, error)
+		}{
+			{1, ManhattanDistance},
+			{2, EuclideanDistance},
+		} {
+			want, _ := tt.want(v1, v2)
+			got, err := MinkowskiDistance(v1, v2, tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-want) > 1e-12 {
+				t.Errorf("pair %d, p=%v: got %v, want %v", i, tt.p, got, want)
+			}
+		}
+	}
+	if got, _ := MinkowskiDistance([]float64{0, 0}, []float64{3, 4}, 3); math.Abs(got-math.Cbrt(91)) > 1e-12 {
+		t.Errorf("p=3: got %v, want %v", got, math.Cbrt(91))
+	}
+
+	for _, p := range []float64{0, -1, math.NaN()} {
+		if _, err := MinkowskiDistance([]float64{1}, []float64{2}, p); !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("p=%v: got err %v, want %v", p, err, ErrInvalidParam)
+		}
+	}
+	if _, err := MinkowskiDistance([]float64{1}, []float64{1, 2}, 2); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := MinkowskiDistance(nil, []float64{1}, 2); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func TestChebyshevDistance(t *testing.T) {
+	tests := []struct {
+		v1, v2 []float64
+		want   float64
+	}{
+		{[]float64{0, 0}, []float64{3, 4}, 4},
+		{[]float64{-5, 1, 2}, []float64{1, -1, 2}, 6},
+		{[]float64{-3, -3}, []float64{-1, -4}, 2},
+		{[]float64{}, []float64{}, 0},
+	}
+	for _, tt := range tests {
+		got, err := ChebyshevDistance(tt.v1, tt.v2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v to %v: got %v, want %v", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+
+	if _, err := ChebyshevDistance([]float64{1}, []float64{1, 2}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := ChebyshevDistance(nil, []float64{1}); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func TestCosineDistance(t *testing.T) {
+	tests := []struct {
+		v1, v2 []float64
+		want   float64
+	}{
+		{[]float64{1, 0}, []float64{0, 3}, 1},
+		{[]float64{2, 5}, []float64{2, 5}, 0},
+		{[]float64{1, 1}, []float64{-2, -2}, 2},
+	}
+	for _, tt := range tests {
+		got, err := CosineDistance(tt.v1, tt.v2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%v to %v: got %v, want %v", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+
+	if _, err := CosineDistance([]float64{1}, []float64{1, 2}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := CosineDistance(nil, []float64{1}); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func TestCosineSimilarityZeroNorm(t *testing.T) {
+	zero, v := []float64{0, 0, 0}, []float64{1, 2, 3}
+	for _, pair := range [][2][]float64{{zero, v}, {v, zero}, {zero, zero}} {
+		got, err := CosineSimilarity(pair[0], pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 0 {
+			t.Errorf("%v, %v: got %v, want 0", pair[0], pair[1], got)
+		}
+	}
+}
+
+func TestWeightedEuclideanDistance(t *testing.T) {
+	v1, v2 := []float64{0, 0, 0}, []float64{3, 4, 12}
+	tests := []struct {
+		weights []float64
+		want    float64
+	}{
+		{[]float64{1, 1, 1}, 13},
+		{[]float64{1, 1, 0}, 5},
+		{[]float64{4, 0, 0}, 6},
+	}
+	for _, tt := range tests {
+		got, err := WeightedEuclideanDistance(v1, v2, tt.weights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("weights %v: got %v, want %v", tt.weights, got, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		v1, v2, weights []float64
+		want            error
+	}{
+		{v1, v2, []float64{1, -1, 1}, ErrInvalidParam},
+		{v1, v2, []float64{1, 1}, ErrDimMismatch},
+		{v1, v2, nil, ErrNilVec},
+		{v1, []float64{1}, []float64{1, 1, 1}, ErrDimMismatch},
+		{nil, v2, []float64{1, 1, 1}, ErrNilVec},
+	}
+	for _, tt := range errTests {
+		if _, err := WeightedEuclideanDistance(tt.v1, tt.v2, tt.weights); !errors.Is(err, tt.want) {
+			t.Errorf("%v, %v, %v: got err %v, want %v", tt.v1, tt.v2, tt.weights, err, tt.want)
+		}
+	}
+}
+
+func TestDotProduct(t *testing.T) {
+	tests := []struct {
+		v1, v2 []float64
+		want   float64
+	}{
+		{[]float64{1, 2, 3}, []float64{4, -5, 6}, 12},
+		{[]float64{1, 0}, []float64{0, 1}, 0},
+		{[]float64{}, []float64{}, 0},
+	}
+	for _, tt := range tests {
+		got, err := DotProduct(tt.v1, tt.v2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v . %v: got %v, want %v", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+
+	if _, err := DotProduct([]float64{1}, []float64{1, 2}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := DotProduct(nil, []float64{1}); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func TestCosineSimilarityHuge(t *testing.T) {
+	big := math.MaxFloat64 / 2
+	v := []float64{big, big, big / 4}
+	if n := Norm(v); !math.IsInf(n, 1) {
+		t.Fatalf("naive norm %v did not overflow; test vector too small", n)
+	}
+	scale, n := scaledNorm(v)
+	if math.IsInf(scale*n, 0) || math.Abs(n-math.Sqrt(2+1./16)) > 1e-15 {
+		t.Errorf("scaled norm: got %v * %v", scale, n)
+	}
+
+	tests := []struct {
+		v1, v2 []float64
+		want   float64
+	}{
+		{v, v, 1},
+		{v, []float64{-big, -big, -big / 4}, -1},
+		{[]float64{big, 0}, []float64{0, big}, 0},
+		{[]float64{big, big}, []float64{1, 1}, 1},
+	}
+	for _, tt := range tests {
+		got, err := CosineSimilarity(tt.v1, tt.v2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%v, %v: got %v, want %v", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+	// The scaled computation agrees with the plain one in normal ranges.
+	vecs := randomUnitVecs(10, 5, 4)
+	for i := 1; i < len(vecs); i++ {
+		Scale(vecs[i], float64(i))
+		want, _ := CosineSimilarity(vecs[i-1], vecs[i])
+		if got := cosineScaled(vecs[i-1], vecs[i]); math.Abs(got-want) > 1e-12 {
+			t.Errorf("pair %d: scaled %v, plain %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package mathutils
+
+// VecGenerator returns a generator over vecs in order, in the form consumed
+// by VecMean and the search funcs: each call yields the next vector and
+// true, then nil and false once vecs is exhausted.
+func VecGenerator(vecs [][]float64) func() ([]float64, bool) {
+	i := 0
+	return func() ([]float64, bool) {
+		if i >= len(vecs) {
+			return nil, false
+		}
+		i++
+		return vecs[i-1], true
+	}
+}
@@ -0,0 +1,58 @@
+package mathutils
+
+// VecMean returns the element-wise mean of all vectors produced by the vecs
+// generator, which signals exhaustion by returning false. Nil vectors are
+// skipped. Returns false if no vectors were seen or if they differ in length.
+func VecMean(vecs func() ([]float64, bool)) ([]float64, bool) {
+	var sum RunningSum
+	for v, ok := vecs(); ok; v, ok = vecs() {
+		if v == nil {
+			continue
+		}
+		if sum.Add(v) != nil {
+			return nil, false
+		}
+	}
+	return sum.Mean()
+}
+
+// WeightedVecMean returns the mean of the vectors produced by vecs, each
+// weighted by the value the weights generator produces alongside it. The two
+// generators are drained in lockstep. Nil vectors are skipped along with
+// their weight, as VecMean skips them. Returns false if the generators
+// produce different numbers of values, if no vectors were seen, if they
+// differ in length, if a weight is negative, or if the weights sum to 0.
+func WeightedVecMean(vecs func() ([]float64, bool), weights func() (float64, bool)) ([]float64, bool) {
+	var mean []float64
+	var total float64
+	for {
+		v, vok := vecs()
+		w, wok := weights()
+		if vok != wok {
+			return nil, false
+		}
+		if !vok {
+			break
+		}
+		if !(w >= 0) {
+			return nil, false
+		}
+		if v == nil {
+			continue
+		}
+		if mean == nil {
+			mean = make([]float64, len(v))
+		}
+		if AddScaled(mean, v, w) != nil {
+			return nil, false
+		}
+		total += w
+	}
+	if mean == nil || total == 0 {
+		return nil, false
+	}
+	for i := range mean {
+		mean[i] /= total
+	}
+	return mean, true
+}
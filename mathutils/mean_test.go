@@ -0,0 +1,55 @@
+package mathutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+// weightGenerator returns a generator over weights.
+func weightGenerator(weights []float64) func() (float64, bool) {
+	i := 0
+	return func() (float64, bool) {
+		if i >= len(weights) {
+			return 0, false
+		}
+		i++
+		return weights[i-1], true
+	}
+}
+
+func TestWeightedVecMean(t *testing.T) {
+	vecs := [][]float64{{0, 0}, nil, {4, 8}, {2, 2}}
+	tests := []struct {
+		weights []float64
+		want    []float64
+	}{
+		{[]float64{1, 5, 1, 0}, []float64{2, 4}},
+		{[]float64{3, 0, 1, 0}, []float64{1, 2}},
+		// Equal weights give VecMean.
+		{[]float64{2, 2, 2, 2}, []float64{2, 10. / 3}},
+	}
+	for _, tt := range tests {
+		got, ok := WeightedVecMean(VecGenerator(vecs), weightGenerator(tt.weights))
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("weights %v: got %v, %v, want %v", tt.weights, got, ok, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		name    string
+		vecs    [][]float64
+		weights []float64
+	}{
+		{"fewer weights", vecs, []float64{1, 1, 1}},
+		{"more weights", vecs, []float64{1, 1, 1, 1, 1}},
+		{"negative weight", vecs, []float64{1, 1, -1, 1}},
+		{"zero total", vecs, []float64{0, 1, 0, 0}},
+		{"no vectors", nil, nil},
+		{"dimension mismatch", [][]float64{{1}, {1, 2}}, []float64{1, 1}},
+	}
+	for _, tt := range invalid {
+		if got, ok := WeightedVecMean(VecGenerator(tt.vecs), weightGenerator(tt.weights)); ok {
+			t.Errorf("%s: got %v, want failure", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package mathutils
+
+// MeanAccumulator maintains the element-wise mean of a stream of vectors in
+// O(dim) per vector, without keeping or revisiting them. It updates the mean
+// directly, Welford-style, rather than dividing a running sum, so the mean
+// stays accurate over long streams of large values; use RunningSum where
+// vectors must also be removed. The zero value is empty; its dimension is
+// fixed by the first Add.
+type MeanAccumulator struct {
+	mean  []float64
+	count int
+}
+
+// Add includes vec in the mean. Returns false, leaving the mean untouched,
+// if vec is nil or its dimension differs from the vectors already added.
+func (m *MeanAccumulator) Add(vec []float64) bool {
+	if vec == nil || (m.count > 0 && len(vec) != len(m.mean)) {
+		return false
+	}
+	if m.count == 0 {
+		m.mean = make([]float64, len(vec))
+	}
+	m.count++
+	for i, x := range vec {
+		m.mean[i] += (x - m.mean[i]) / float64(m.count)
+	}
+	return true
+}
+
+// Count returns the number of vectors added.
+func (m *MeanAccumulator) Count() int {
+	return m.count
+}
+
+// Mean returns a copy of the mean of the vectors added. Returns false if
+// none have been added.
+func (m *MeanAccumulator) Mean() ([]float64, bool) {
+	if m.count == 0 {
+		return nil, false
+	}
+	return append([]float64(nil), m.mean...), true
+}
@@ -0,0 +1,44 @@
+package mathutils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMeanAccumulator(t *testing.T) {
+	var m MeanAccumulator
+	if _, ok := m.Mean(); ok {
+		t.Error("empty accumulator has a mean")
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	vecs := make([][]float64, 100)
+	for i := range vecs {
+		vecs[i] = []float64{rng.NormFloat64() * 1e9, rng.Float64()}
+		if !m.Add(vecs[i]) {
+			t.Fatalf("rejected vec %d", i)
+		}
+	}
+	want, _ := VecMean(VecGenerator(vecs))
+	got, ok := m.Mean()
+	if !ok || m.Count() != len(vecs) {
+		t.Fatalf("got count %d, ok %v", m.Count(), ok)
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9*math.Max(1, math.Abs(want[i])) {
+			t.Errorf("element %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if m.Add([]float64{1}) || m.Add(nil) {
+		t.Error("accepted invalid vector")
+	}
+	if m.Count() != len(vecs) {
+		t.Errorf("rejected vectors counted: %d", m.Count())
+	}
+	got[0] = 0
+	if again, _ := m.Mean(); again[0] == 0 {
+		t.Error("Mean aliases internal state")
+	}
+}
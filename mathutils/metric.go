@@ -0,0 +1,88 @@
+package mathutils
+
+import "fmt"
+
+// Metric is a distance function between two vectors, where a smaller value
+// means the vectors are closer. The distance functions in this package, such
+// as EuclideanDistance, satisfy it.
+type Metric func(v1, v2 []float64) (float64, error)
+
+// metrics maps the names accepted by MetricByName to their metric.
+var metrics = map[string]Metric{
+	"euclidean": EuclideanDistance,
+	"cosine":    CosineDistance,
+	"manhattan": ManhattanDistance,
+	"chebyshev": ChebyshevDistance,
+}
+
+// MetricByName returns the metric called name: "euclidean" for
+// EuclideanDistance, "manhattan" for ManhattanDistance, "chebyshev" for
+// ChebyshevDistance, or "cosine" for CosineDistance. Returns false for any
+// other name.
+func MetricByName(name string) (Metric, bool) {
+	m, ok := metrics[name]
+	return m, ok
+}
+
+// DistanceMatrix returns the symmetric matrix of distances under metric
+// between every pair of vecs, so that m[i][j] is the distance from vecs[i]
+// to vecs[j]. Only the upper triangle is computed and mirrored. The
+// diagonal holds each vector's distance to itself, which is zero for the
+// usual metrics. Returns the first error metric returns, e.g. when two
+// vectors differ in length.
+func DistanceMatrix(vecs [][]float64, metric Metric) ([][]float64, error) {
+	m := make([][]float64, len(vecs))
+	for i := range m {
+		m[i] = make([]float64, len(vecs))
+	}
+	for i := range vecs {
+		for j := i; j < len(vecs); j++ {
+			d, err := metric(vecs[i], vecs[j])
+			if err != nil {
+				return nil, err
+			}
+			m[i][j], m[j][i] = d, d
+		}
+	}
+	return m, nil
+}
+
+// Distances drains vecs and returns the distance under metric from target to
+// each vector, in generation order. It stops at the first vector metric
+// fails for and returns the error wrapped with that vector's index, so
+// errors.Is still matches the metric's error. Nil vectors, such as those
+// some generators yield for skipped entries, fail like any other.
+func Distances(target []float64, vecs func() ([]float64, bool), metric Metric) ([]float64, error) {
+	dists := []float64{}
+	for i := 0; ; i++ {
+		v, ok := vecs()
+		if !ok {
+			return dists, nil
+		}
+		d, err := metric(target, v)
+		if err != nil {
+			return nil, fmt.Errorf("vector %d: %w", i, err)
+		}
+		dists = append(dists, d)
+	}
+}
+
+// defaultMetric is the metric used by Distance.
+var defaultMetric Metric = EuclideanDistance
+
+// SetDefaultMetric makes m the metric used by Distance, so an application
+// can switch its notion of distance in one place. A nil m restores
+// EuclideanDistance. It is not safe to call concurrently with Distance:
+// set the default during initialization, before any distances are taken.
+func SetDefaultMetric(m Metric) {
+	if m == nil {
+		m = EuclideanDistance
+	}
+	defaultMetric = m
+}
+
+// Distance returns the distance between v1 and v2 under the default metric
+// (see SetDefaultMetric), which is EuclideanDistance unless changed.
+func Distance(v1, v2 []float64) (float64, error) {
+	return defaultMetric(v1, v2)
+}
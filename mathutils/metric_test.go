@@ -0,0 +1,91 @@
+package mathutils
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMetricByName(t *testing.T) {
+	v1, v2 := []float64{1, 0}, []float64{0, 2}
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"euclidean", 2.23606797749979},
+		{"cosine", 1},
+		{"manhattan", 3},
+		{"chebyshev", 2},
+	}
+	for _, tt := range tests {
+		m, ok := MetricByName(tt.name)
+		if !ok {
+			t.Fatalf("%s: not found", tt.name)
+		}
+		if got, err := m(v1, v2); err != nil || got != tt.want {
+			t.Errorf("%s: got %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, ok := MetricByName("Euclidean"); ok {
+		t.Error("found metric for unknown name")
+	}
+}
+
+func TestSetDefaultMetric(t *testing.T) {
+	t.Cleanup(func() { SetDefaultMetric(nil) })
+	v1, v2 := []float64{3, 0}, []float64{0, 4}
+
+	if got, _ := Distance(v1, v2); got != 5 {
+		t.Errorf("default: got %v, want 5", got)
+	}
+	cosine, _ := MetricByName("cosine")
+	SetDefaultMetric(cosine)
+	if got, _ := Distance(v1, v2); got != 1 {
+		t.Errorf("cosine: got %v, want 1", got)
+	}
+	SetDefaultMetric(nil)
+	if got, _ := Distance(v1, v2); got != 5 {
+		t.Errorf("restored: got %v, want 5", got)
+	}
+}
+
+func TestDistanceMatrix(t *testing.T) {
+	vecs := [][]float64{{0, 0}, {3, 4}, {6, 0}}
+	got, err := DistanceMatrix(vecs, EuclideanDistance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{
+		{0, 5, 6},
+		{5, 0, 5},
+		{6, 5, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got, err := DistanceMatrix(nil, EuclideanDistance); err != nil || len(got) != 0 {
+		t.Errorf("empty: got %v, %v", got, err)
+	}
+	if _, err := DistanceMatrix([][]float64{{0}, {1, 2}}, EuclideanDistance); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+}
+
+func TestDistances(t *testing.T) {
+	vecs := [][]float64{{3, 4}, {0, 0}, {-6, 8}}
+	got, err := Distances([]float64{0, 0}, VecGenerator(vecs), EuclideanDistance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{5, 0, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	vecs = [][]float64{{1, 1}, {1}, nil}
+	_, err = Distances([]float64{0, 0}, VecGenerator(vecs), EuclideanDistance)
+	if !errors.Is(err, ErrDimMismatch) || !strings.Contains(err.Error(), "vector 1") {
+		t.Errorf("got err %v, want %v at vector 1", err, ErrDimMismatch)
+	}
+}
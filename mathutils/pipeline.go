@@ -0,0 +1,209 @@
+package mathutils
+
+import "errors"
+
+// ErrNotFitted is returned by Pipeline.Transform before the pipeline has
+// been fitted.
+var ErrNotFitted = errors.New("mathutils: pipeline not fitted")
+
+// Step is one stage of a Pipeline.
+type Step interface {
+	// Fit learns the parameters of the step from vecs, as transformed by
+	// the steps before it. vecs must not be modified.
+	Fit(vecs [][]float64) error
+	// Transform returns vec transformed with the fitted parameters,
+	// without modifying vec.
+	Transform(vec []float64) ([]float64, error)
+}
+
+// Pipeline chains preprocessing steps, e.g. Standardizer then Normalizer.
+// Fitting it on training data and then running every query through
+// Transform applies exactly the transforms the training data went through,
+// which avoids skew between the two. A Pipeline must not be fitted
+// concurrently with other calls; once fitted, Transform is safe for
+// concurrent use.
+type Pipeline struct {
+	steps  []Step
+	dim    int
+	fitted bool
+}
+
+// NewPipeline returns an unfitted pipeline running steps in order.
+func NewPipeline(steps ...Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Fit fits every step in order, each on the output of the previous ones.
+// Returns an error if vecs is empty, holds nil vectors or vectors of
+// differing dimension, or if a step fails to fit; the pipeline is then
+// left unfitted.
+func (p *Pipeline) Fit(vecs [][]float64) error {
+	_, err := p.FitTransform(vecs)
+	return err
+}
+
+// FitTransform is Fit that also returns vecs transformed by the fitted
+// pipeline, as Transform would transform each of them.
+func (p *Pipeline) FitTransform(vecs [][]float64) ([][]float64, error) {
+	p.fitted = false
+	if len(vecs) == 0 {
+		return nil, ErrNilVec
+	}
+	for _, v := range vecs {
+		if err := checkPair(vecs[0], v); err != nil {
+			return nil, err
+		}
+	}
+
+	out := vecs
+	for _, step := range p.steps {
+		if err := step.Fit(out); err != nil {
+			return nil, err
+		}
+		next := make([][]float64, len(out))
+		for i, v := range out {
+			t, err := step.Transform(v)
+			if err != nil {
+				return nil, err
+			}
+			next[i] = t
+		}
+		out = next
+	}
+	if len(p.steps) == 0 {
+		out = make([][]float64, len(vecs))
+		for i, v := range vecs {
+			out[i] = append([]float64(nil), v...)
+		}
+	}
+	p.dim, p.fitted = len(vecs[0]), true
+	return out, nil
+}
+
+// Transform returns vec run through every step. vec is not modified.
+// Returns ErrNotFitted before Fit has succeeded, or an error if vec is nil
+// or its dimension differs from the training data.
+func (p *Pipeline) Transform(vec []float64) ([]float64, error) {
+	if !p.fitted {
+		return nil, ErrNotFitted
+	}
+	if vec == nil {
+		return nil, ErrNilVec
+	}
+	if len(vec) != p.dim {
+		return nil, ErrDimMismatch
+	}
+	out := append([]float64(nil), vec...)
+	for _, step := range p.steps {
+		var err error
+		if out, err = step.Transform(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// normalizer is the Step returned by Normalizer.
+type normalizer struct{}
+
+// Normalizer returns a Step scaling vectors to unit length, as Normalize
+// does. Zero vectors are passed through unchanged. It has nothing to fit.
+func Normalizer() Step {
+	return normalizer{}
+}
+
+func (normalizer) Fit([][]float64) error { return nil }
+
+func (normalizer) Transform(vec []float64) ([]float64, error) {
+	if vec == nil {
+		return nil, ErrNilVec
+	}
+	out := append([]float64(nil), vec...)
+	Normalize(out)
+	return out, nil
+}
+
+// standardizer is the Step returned by Standardizer.
+type standardizer struct {
+	mean, std []float64
+}
+
+// Standardizer returns a Step shifting and scaling every dimension to zero
+// mean and unit (population) standard deviation over the fitted vectors.
+// Dimensions that are constant in the fitted vectors are only shifted.
+func Standardizer() Step {
+	return &standardizer{}
+}
+
+func (s *standardizer) Fit(vecs [][]float64) error {
+	if len(vecs) == 0 || vecs[0] == nil {
+		return ErrNilVec
+	}
+	stats := make([]RunningVariance, len(vecs[0]))
+	for _, v := range vecs {
+		if err := checkPair(vecs[0], v); err != nil {
+			return err
+		}
+		for i, x := range v {
+			stats[i].Add(x)
+		}
+	}
+	s.mean = make([]float64, len(stats))
+	s.std = make([]float64, len(stats))
+	for i := range stats {
+		s.mean[i], s.std[i] = stats[i].Mean(), stats[i].StdDev()
+	}
+	return nil
+}
+
+func (s *standardizer) Transform(vec []float64) ([]float64, error) {
+	if err := checkPair(s.mean, vec); err != nil {
+		return nil, err
+	}
+	out := make([]float64, len(vec))
+	for i, x := range vec {
+		out[i] = x - s.mean[i]
+		if s.std[i] > 0 {
+			out[i] /= s.std[i]
+		}
+	}
+	return out, nil
+}
+
+// projector is the Step returned by Projector.
+type projector struct {
+	basis [][]float64
+}
+
+// Projector returns a Step projecting vectors onto basis: the output has
+// one element per basis vector, its dot product with the input. With
+// fewer basis vectors than dimensions this reduces dimensionality, e.g.
+// onto principal components computed elsewhere. Fit fails if the basis
+// vectors don't match the dimension of the fitted vectors.
+func Projector(basis [][]float64) Step {
+	return &projector{basis: basis}
+}
+
+func (p *projector) Fit(vecs [][]float64) error {
+	if len(vecs) == 0 || len(p.basis) == 0 {
+		return ErrNilVec
+	}
+	for _, b := range p.basis {
+		if err := checkPair(vecs[0], b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *projector) Transform(vec []float64) ([]float64, error) {
+	out := make([]float64, len(p.basis))
+	for i, b := range p.basis {
+		dot, err := DotProduct(b, vec)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = dot
+	}
+	return out, nil
+}
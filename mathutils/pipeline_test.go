@@ -0,0 +1,76 @@
+package mathutils
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vecs := make([][]float64, 50)
+	for i := range vecs {
+		vecs[i] = []float64{rng.NormFloat64()*10 + 3, rng.NormFloat64() - 5, rng.Float64()}
+	}
+	p := NewPipeline(
+		Standardizer(),
+		Projector([][]float64{{1, 0, 0}, {0, 1, 1}}),
+		Normalizer(),
+	)
+	if _, err := p.Transform(vecs[0]); !errors.Is(err, ErrNotFitted) {
+		t.Errorf("unfitted: got err %v, want %v", err, ErrNotFitted)
+	}
+
+	train, err := p.FitTransform(vecs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vecs {
+		before := append([]float64(nil), v...)
+		got, err := p.Transform(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, train[i]) {
+			t.Fatalf("vec %d: got %v, training gave %v", i, got, train[i])
+		}
+		if !reflect.DeepEqual(v, before) {
+			t.Fatalf("vec %d modified", i)
+		}
+		if len(got) != 2 || math.Abs(Norm(got)-1) > 1e-12 {
+			t.Errorf("vec %d: got %v, want a unit 2-vector", i, got)
+		}
+	}
+
+	if _, err := p.Transform([]float64{1}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if err := p.Fit(nil); err == nil {
+		t.Error("fitted on no vectors")
+	}
+	if _, err := p.Transform(vecs[0]); !errors.Is(err, ErrNotFitted) {
+		t.Errorf("after failed fit: got err %v, want %v", err, ErrNotFitted)
+	}
+}
+
+func TestStandardizer(t *testing.T) {
+	s := Standardizer()
+	if err := s.Fit([][]float64{{1, 7}, {3, 7}, {5, 7}}); err != nil {
+		t.Fatal(err)
+	}
+	// std of {1, 3, 5} is sqrt(8/3); the constant dimension is only
+	// shifted.
+	got, _ := s.Transform([]float64{3 + math.Sqrt(8./3), 8})
+	if want := []float64{1, 1}; math.Abs(got[0]-want[0]) > 1e-12 || got[1] != want[1] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestProjectorInvalid(t *testing.T) {
+	p := NewPipeline(Projector([][]float64{{1, 0}}))
+	if err := p.Fit([][]float64{{1, 2, 3}}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+}
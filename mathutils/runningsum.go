@@ -0,0 +1,74 @@
+package mathutils
+
+import "errors"
+
+// ErrEmptySum is returned by RunningSum.Remove when there is nothing left
+// to remove.
+var ErrEmptySum = errors.New("mathutils: remove from empty running sum")
+
+// RunningSum maintains the element-wise sum and count of a multiset of
+// vectors, so their mean can be kept up to date in O(dim) as vectors are
+// added and removed. The zero value is an empty sum; its dimension is fixed
+// by the first Add and released again when the count drops back to zero.
+type RunningSum struct {
+	sum   []float64
+	count int
+}
+
+// Add includes vec in the sum. Returns an error if vec is nil or its
+// dimension differs from the vectors already added.
+func (r *RunningSum) Add(vec []float64) error {
+	if vec == nil {
+		return ErrNilVec
+	}
+	if r.count == 0 {
+		r.sum = make([]float64, len(vec))
+	}
+	if err := Add(r.sum, vec); err != nil {
+		return err
+	}
+	r.count++
+	return nil
+}
+
+// Remove takes vec back out of the sum. It is the caller's responsibility
+// that vec was previously added. Returns an error if the sum is empty, so
+// the count can never go negative, or on the same conditions as Add.
+func (r *RunningSum) Remove(vec []float64) error {
+	if r.count == 0 {
+		return ErrEmptySum
+	}
+	if err := Sub(r.sum, vec); err != nil {
+		return err
+	}
+	r.count--
+	if r.count == 0 {
+		// Drop accumulated rounding error along with the dimension.
+		r.sum = nil
+	}
+	return nil
+}
+
+// Count returns the number of vectors currently in the sum.
+func (r *RunningSum) Count() int {
+	return r.count
+}
+
+// Sum returns a copy of the element-wise sum, or nil if the sum is empty.
+func (r *RunningSum) Sum() []float64 {
+	if r.count == 0 {
+		return nil
+	}
+	return append([]float64(nil), r.sum...)
+}
+
+// Mean returns the element-wise mean of the vectors currently in the sum,
+// or false if the sum is empty.
+func (r *RunningSum) Mean() ([]float64, bool) {
+	if r.count == 0 {
+		return nil, false
+	}
+	mean := r.Sum()
+	Scale(mean, 1/float64(r.count))
+	return mean, true
+}
@@ -0,0 +1,78 @@
+package mathutils
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestRunningSumAddRemoveRestoresMean(t *testing.T) {
+	var r RunningSum
+	for _, v := range [][]float64{{1, 2}, {3, 4}, {-1, 0.5}} {
+		if err := r.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, _ := r.Mean()
+
+	extra := []float64{1e3, -7.25}
+	if err := r.Add(extra); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Remove(extra); err != nil {
+		t.Fatal(err)
+	}
+
+	after, ok := r.Mean()
+	if !ok {
+		t.Fatal("unexpected empty mean")
+	}
+	for i := range before {
+		if math.Abs(before[i]-after[i]) > 1e-9 {
+			t.Errorf("dim %d: got %v, want %v", i, after[i], before[i])
+		}
+	}
+	if r.Count() != 3 {
+		t.Errorf("got count %d, want 3", r.Count())
+	}
+}
+
+func TestRunningSumMean(t *testing.T) {
+	var r RunningSum
+	if _, ok := r.Mean(); ok {
+		t.Error("empty sum reported a mean")
+	}
+	r.Add([]float64{1, 2})
+	r.Add([]float64{3, 6})
+	mean, ok := r.Mean()
+	if !ok || mean[0] != 2 || mean[1] != 4 {
+		t.Errorf("got %v (%v), want [2 4]", mean, ok)
+	}
+}
+
+func TestRunningSumGuards(t *testing.T) {
+	var r RunningSum
+	if err := r.Remove([]float64{1}); !errors.Is(err, ErrEmptySum) {
+		t.Errorf("remove from empty: got err %v, want %v", err, ErrEmptySum)
+	}
+	if r.Count() != 0 {
+		t.Errorf("count went to %d", r.Count())
+	}
+
+	r.Add([]float64{1, 2})
+	if err := r.Add([]float64{1}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("add mismatch: got err %v, want %v", err, ErrDimMismatch)
+	}
+	if err := r.Remove([]float64{1}); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("remove mismatch: got err %v, want %v", err, ErrDimMismatch)
+	}
+	if r.Count() != 1 {
+		t.Errorf("got count %d, want 1", r.Count())
+	}
+
+	// Emptying the sum releases the dimension.
+	r.Remove([]float64{1, 2})
+	if err := r.Add([]float64{1, 2, 3}); err != nil {
+		t.Errorf("re-add after empty: %v", err)
+	}
+}
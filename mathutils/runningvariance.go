@@ -0,0 +1,50 @@
+package mathutils
+
+import "math"
+
+// RunningVariance maintains the count, mean and variance of a stream of
+// values in O(1) per value with Welford's algorithm, which stays accurate
+// where the naive sum-of-squares formula cancels catastrophically. The zero
+// value is empty.
+type RunningVariance struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// Add includes x in the statistics.
+func (r *RunningVariance) Add(x float64) {
+	r.count++
+	delta := x - r.mean
+	r.mean += delta / float64(r.count)
+	r.m2 += delta * (x - r.mean)
+}
+
+// Count returns the number of values added.
+func (r *RunningVariance) Count() int {
+	return r.count
+}
+
+// Mean returns the mean of the values added, or 0 if there are none.
+func (r *RunningVariance) Mean() float64 {
+	return r.mean
+}
+
+// Variance returns the population variance of the values added, or 0 if
+// there are none.
+func (r *RunningVariance) Variance() float64 {
+	if r.count == 0 {
+		return 0
+	}
+	return r.m2 / float64(r.count)
+}
+
+// StdDev returns the population standard deviation of the values added.
+func (r *RunningVariance) StdDev() float64 {
+	return math.Sqrt(r.Variance())
+}
+
+// Reset empties the statistics.
+func (r *RunningVariance) Reset() {
+	*r = RunningVariance{}
+}
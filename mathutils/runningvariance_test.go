@@ -0,0 +1,34 @@
+package mathutils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRunningVariance(t *testing.T) {
+	var r RunningVariance
+	if r.Mean() != 0 || r.Variance() != 0 {
+		t.Error("empty statistics not zero")
+	}
+	// A large offset breaks the naive sum-of-squares formula.
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		r.Add(1e9 + x)
+	}
+	if r.Count() != 8 {
+		t.Errorf("got count %d, want 8", r.Count())
+	}
+	if got := r.Mean(); got != 1e9+5 {
+		t.Errorf("got mean %v, want %v", got, 1e9+5)
+	}
+	if got := r.Variance(); math.Abs(got-4) > 1e-6 {
+		t.Errorf("got variance %v, want 4", got)
+	}
+	if got := r.StdDev(); math.Abs(got-2) > 1e-6 {
+		t.Errorf("got std dev %v, want 2", got)
+	}
+
+	r.Reset()
+	if r.Count() != 0 || r.Mean() != 0 {
+		t.Error("reset didn't empty the statistics")
+	}
+}
@@ -0,0 +1,121 @@
+package mathutils
+
+// Add adds v to dst element-wise, in place. Returns an error (and leaves dst
+// untouched) if either vector is nil or if they differ in length.
+func Add(dst, v []float64) error {
+	return AddScaled(dst, v, 1)
+}
+
+// Sub subtracts v from dst element-wise, in place. Returns an error (and
+// leaves dst untouched) if either vector is nil or if they differ in length.
+func Sub(dst, v []float64) error {
+	return AddScaled(dst, v, -1)
+}
+
+// Scale multiplies every element of v by f, in place.
+func Scale(v []float64, f float64) {
+	for i := range v {
+		v[i] *= f
+	}
+}
+
+// VecAdd returns a new vector v1 + v2. Returns an error if either vector is
+// nil or if they differ in length.
+func VecAdd(v1, v2 []float64) ([]float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return nil, err
+	}
+	out := append([]float64(nil), v1...)
+	Add(out, v2)
+	return out, nil
+}
+
+// VecSub returns a new vector v1 - v2. Returns an error if either vector is
+// nil or if they differ in length.
+func VecSub(v1, v2 []float64) ([]float64, error) {
+	if err := checkPair(v1, v2); err != nil {
+		return nil, err
+	}
+	out := append([]float64(nil), v1...)
+	Sub(out, v2)
+	return out, nil
+}
+
+// VecScale returns a new vector: vec with every element multiplied by f.
+func VecScale(vec []float64, f float64) []float64 {
+	out := append([]float64(nil), vec...)
+	Scale(out, f)
+	return out
+}
+
+// AddScaled adds f*v to dst element-wise, in place. Returns an error (and
+// leaves dst untouched) if either vector is nil or if they differ in length.
+func AddScaled(dst, v []float64, f float64) error {
+	if err := checkPair(dst, v); err != nil {
+		return err
+	}
+	for i := range dst {
+		dst[i] += f * v[i]
+	}
+	return nil
+}
+
+// IsZero reports whether every element of vec is zero. Such vectors have no
+// direction, so cosine comparisons with them are meaningless.
+func IsZero(vec []float64) bool {
+	for _, x := range vec {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalize scales vec to unit length, in place. Returns false, leaving vec
+// untouched, if vec is a zero vector.
+func Normalize(vec []float64) bool {
+	n := Norm(vec)
+	if n == 0 {
+		return false
+	}
+	for i := range vec {
+		vec[i] /= n
+	}
+	return true
+}
+
+// VecNormalize returns a new vector: vec scaled to unit length. vec itself is
+// not modified. Returns false if vec is a zero vector, in which case the new
+// vector is an unscaled copy.
+func VecNormalize(vec []float64) ([]float64, bool) {
+	out := append([]float64(nil), vec...)
+	return out, Normalize(out)
+}
+
+// NormalizeBatch returns new vectors: each of vecs scaled to unit length,
+// e.g. to prepare a dataset for spherical k-means. vecs are not modified.
+// Zero vectors can't be scaled and come back as unscaled copies (see
+// VecNormalize) rather than NaN; nil vectors stay nil.
+func NormalizeBatch(vecs [][]float64) [][]float64 {
+	out := make([][]float64, len(vecs))
+	for i, v := range vecs {
+		if v != nil {
+			out[i], _ = VecNormalize(v)
+		}
+	}
+	return out
+}
+
+// Lerp returns a new vector (1-t)*a + t*b, interpolating linearly from a at
+// t=0 to b at t=1; other values of t extrapolate. Returns an error if
+// either vector is nil or if they differ in length.
+func Lerp(a, b []float64, t float64) ([]float64, error) {
+	if err := checkPair(a, b); err != nil {
+		return nil, err
+	}
+	v := make([]float64, len(a))
+	for i := range v {
+		v[i] = (1-t)*a[i] + t*b[i]
+	}
+	return v, nil
+}
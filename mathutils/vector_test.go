@@ -0,0 +1,233 @@
+package mathutils
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	dst := []float64{1, 2, 3}
+	if err := Add(dst, []float64{1, -1, 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{2, 1, 3.5}; !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
+
+func TestSub(t *testing.T) {
+	dst := []float64{1, 2, 3}
+	if err := Sub(dst, []float64{1, -1, 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0, 3, 2.5}; !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
+
+func TestScale(t *testing.T) {
+	v := []float64{1, -2, 0}
+	Scale(v, 2)
+	if want := []float64{2, -4, 0}; !reflect.DeepEqual(v, want) {
+		t.Errorf("got %v, want %v", v, want)
+	}
+}
+
+func TestAddScaled(t *testing.T) {
+	dst := []float64{1, 1}
+	if err := AddScaled(dst, []float64{2, 4}, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{2, 3}; !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
+
+func TestVectorOpsInvalid(t *testing.T) {
+	ops := map[string]func(dst, v []float64) error{
+		"Add":       Add,
+		"Sub":       Sub,
+		"AddScaled": func(dst, v []float64) error { return AddScaled(dst, v, 2) },
+	}
+	for name, op := range ops {
+		dst := []float64{1, 2}
+		if err := op(dst, []float64{1}); !errors.Is(err, ErrDimMismatch) {
+			t.Errorf("%s: got err %v, want %v", name, err, ErrDimMismatch)
+		}
+		if err := op(dst, nil); !errors.Is(err, ErrNilVec) {
+			t.Errorf("%s: got err %v, want %v", name, err, ErrNilVec)
+		}
+		if want := []float64{1, 2}; !reflect.DeepEqual(dst, want) {
+			t.Errorf("%s: dst modified on error: got %v, want %v", name, dst, want)
+		}
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	if !IsZero([]float64{0, 0}) || !IsZero(nil) {
+		t.Error("zero vector not detected")
+	}
+	if IsZero([]float64{0, 1e-300}) {
+		t.Error("non-zero vector reported as zero")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	v := []float64{3, 4}
+	if !Normalize(v) {
+		t.Fatal("failed to normalize")
+	}
+	if want := []float64{0.6, 0.8}; !reflect.DeepEqual(v, want) {
+		t.Errorf("got %v, want %v", v, want)
+	}
+
+	zero := []float64{0, 0}
+	if Normalize(zero) {
+		t.Error("normalized a zero vector")
+	}
+	if want := []float64{0, 0}; !reflect.DeepEqual(zero, want) {
+		t.Errorf("zero vector modified to %v", zero)
+	}
+}
+
+func TestLerp(t *testing.T) {
+	a, b := []float64{0, 10}, []float64{4, -2}
+	tests := []struct {
+		t    float64
+		want []float64
+	}{
+		{0, []float64{0, 10}},
+		{1, []float64{4, -2}},
+		{0.5, []float64{2, 4}},
+	}
+	for _, tt := range tests {
+		got, err := Lerp(a, b, tt.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("t=%v: got %v, want %v", tt.t, got, tt.want)
+		}
+	}
+	if want := []float64{0, 10}; !reflect.DeepEqual(a, want) {
+		t.Errorf("a modified to %v", a)
+	}
+
+	if _, err := Lerp(a, []float64{1}, 0.5); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("got err %v, want %v", err, ErrDimMismatch)
+	}
+	if _, err := Lerp(nil, b, 0.5); !errors.Is(err, ErrNilVec) {
+		t.Errorf("got err %v, want %v", err, ErrNilVec)
+	}
+}
+
+func TestVecNormalize(t *testing.T) {
+	vecs := [][]float64{{3, 4}, {-1e-3, 2e-3, 5e-4}, {1e6, -1e6}}
+	for _, v := range vecs {
+		before := append([]float64(nil), v...)
+		got, ok := VecNormalize(v)
+		if !ok {
+			t.Fatalf("%v: not normalized", v)
+		}
+		if math.Abs(Norm(got)-1) > 1e-12 {
+			t.Errorf("%v: got norm %v, want 1", v, Norm(got))
+		}
+		if !reflect.DeepEqual(v, before) {
+			t.Errorf("%v: modified input", before)
+		}
+	}
+
+	zero := []float64{0, 0}
+	got, ok := VecNormalize(zero)
+	if ok || !reflect.DeepEqual(got, zero) {
+		t.Errorf("zero: got %v, %v, want %v, false", got, ok, zero)
+	}
+	got[0] = 1
+	if zero[0] != 0 {
+		t.Error("zero: result aliases input")
+	}
+}
+
+func TestNormalizeBatch(t *testing.T) {
+	vecs := [][]float64{{3, 4}, {0, 0, 0}, nil, {-2, 0, 1e-9}, {0}}
+	got := NormalizeBatch(vecs)
+	if len(got) != len(vecs) {
+		t.Fatalf("got %d vectors, want %d", len(got), len(vecs))
+	}
+	for i, v := range got {
+		for _, x := range v {
+			if math.IsNaN(x) {
+				t.Fatalf("vec %d: got %v", i, v)
+			}
+		}
+		switch i {
+		case 1, 4:
+			if !reflect.DeepEqual(v, vecs[i]) {
+				t.Errorf("vec %d: got %v, want unchanged %v", i, v, vecs[i])
+			}
+		case 2:
+			if v != nil {
+				t.Errorf("vec %d: got %v, want nil", i, v)
+			}
+		default:
+			if math.Abs(Norm(v)-1) > 1e-12 {
+				t.Errorf("vec %d: got norm %v, want 1", i, Norm(v))
+			}
+		}
+	}
+	if vecs[0][0] != 3 {
+		t.Error("modified input")
+	}
+}
+
+func TestNorm(t *testing.T) {
+	if got := Norm([]float64{3, -4}); got != 5 {
+		t.Errorf("got %v, want 5", got)
+	}
+	if got := Norm(nil); got != 0 {
+		t.Errorf("nil: got %v, want 0", got)
+	}
+}
+
+func TestVecArithmetic(t *testing.T) {
+	v1, v2 := []float64{1, 2, 3}, []float64{1, -1, 0.5}
+	sum, err := VecAdd(v1, v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := VecSub(v1, v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled := VecScale(v1, -2)
+	tests := []struct {
+		name      string
+		got, want []float64
+	}{
+		{"VecAdd", sum, []float64{2, 1, 3.5}},
+		{"VecSub", diff, []float64{0, 3, 2.5}},
+		{"VecScale", scaled, []float64{-2, -4, -6}},
+		// The inputs are left alone.
+		{"v1", v1, []float64{1, 2, 3}},
+		{"v2", v2, []float64{1, -1, 0.5}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	for name, fn := range map[string]func(v1, v2 []float64) ([]float64, error){
+		"VecAdd": VecAdd,
+		"VecSub": VecSub,
+	} {
+		if _, err := fn([]float64{1}, []float64{1, 2}); !errors.Is(err, ErrDimMismatch) {
+			t.Errorf("%s: got err %v, want %v", name, err, ErrDimMismatch)
+		}
+		if _, err := fn(nil, []float64{1}); !errors.Is(err, ErrNilVec) {
+			t.Errorf("%s: got err %v, want %v", name, err, ErrNilVec)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package network serves k-means models over HTTP.
+//
+// Routes are registered with method patterns such as "POST /query", so the
+// package needs Go 1.22 or later, and a main module declaring at least go
+// 1.22: under older language versions net/http matches the patterns as
+// literal paths and every route answers 404.
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/kmeans"
+)
+
+// defaultIngestQueueSize is the ingest queue capacity used when
+// NewHandlerArgs doesn't set one.
+const defaultIngestQueueSize = 1024
+
+// maxStreamLine is the longest NDJSON line accepted by POST /ingest/stream.
+const maxStreamLine = 1 << 20
+
+// Handler is an http.Handler exposing a KMeans model. All access to the
+// model goes through the handler's lock, so the model must not be used
+// elsewhere while it is being served. Streamed ingestion goes through a
+// bounded queue drained by a background worker; call Close to stop it.
+type Handler struct {
+	mu      sync.Mutex
+	model   *kmeans.KMeans
+	clock   func() time.Time
+	factory *common.PayloadFactory
+	mux     *http.ServeMux
+
+	queue     chan ingestJob
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// ingestJob is an item waiting in the ingest queue. done is called with the
+// outcome once the item has been routed (or dropped on Close).
+type ingestJob struct {
+	item IngestItem
+	done func(ok bool)
+}
+
+// NewHandlerArgs is the argument set for NewHandler.
+type NewHandlerArgs struct {
+	// Model is the (fitted) model to serve. Required.
+	Model *kmeans.KMeans
+	// Clock stamps ingested payloads and decides when their TTLs run out.
+	// Defaults to time.Now.
+	Clock func() time.Time
+	// PayloadFactory rebuilds typed payloads sent for ingestion (see
+	// IngestItem.Type). Defaults to an empty factory, accepting only
+	// untyped vectors.
+	PayloadFactory *common.PayloadFactory
+	// IngestQueueSize bounds the number of streamed items waiting to be
+	// routed. Once it is full, streaming requests stop reading their body
+	// until there is room, pushing back on the client. Defaults to 1024.
+	IngestQueueSize int
+}
+
+// NewHandler creates a Handler serving args.Model and starts its ingest
+// worker. Returns false if the model is nil or IngestQueueSize is negative.
+// Its routing needs Go 1.22; see the package doc.
+func NewHandler(args NewHandlerArgs) (*Handler, bool) {
+	if args.Model == nil || args.IngestQueueSize < 0 {
+		return nil, false
+	}
+	if args.Clock == nil {
+		args.Clock = time.Now
+	}
+	if args.PayloadFactory == nil {
+		args.PayloadFactory = &common.PayloadFactory{}
+	}
+	if args.IngestQueueSize == 0 {
+		args.IngestQueueSize = defaultIngestQueueSize
+	}
+	h := &Handler{
+		model:   args.Model,
+		clock:   args.Clock,
+		factory: args.PayloadFactory,
+		mux:     http.NewServeMux(),
+		queue:   make(chan ingestJob, args.IngestQueueSize),
+		quit:    make(chan struct{}),
+	}
+	h.mux.HandleFunc("POST /ingest/batch", h.ingestBatch)
+	h.mux.HandleFunc("POST /ingest/stream", h.ingestStream)
+	h.mux.HandleFunc("POST /query", h.query)
+	h.mux.HandleFunc("POST /query/stream", h.queryStream)
+	h.mux.HandleFunc("GET /centroids", h.centroids)
+	go h.runIngest()
+	return h, true
+}
+
+// Close stops the ingest worker. Items still queued are dropped and
+// reported as rejected, and later streamed items are rejected outright.
+// Close is safe to call more than once.
+func (h *Handler) Close() {
+	h.closeOnce.Do(func() { close(h.quit) })
+}
+
+// runIngest routes queued items into the model until the handler is closed.
+func (h *Handler) runIngest() {
+	for {
+		select {
+		case job := <-h.queue:
+			h.mu.Lock()
+			ok := h.ingest(job.item)
+			h.mu.Unlock()
+			job.done(ok)
+		case <-h.quit:
+			for {
+				select {
+				case job := <-h.queue:
+					job.done(false)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// enqueue adds job to the ingest queue, blocking while it is full. Returns
+// false, without calling job.done, if the handler is closed first.
+func (h *Handler) enqueue(job ingestJob) bool {
+	select {
+	case <-h.quit:
+		return false
+	default:
+	}
+	select {
+	case h.queue <- job:
+		return true
+	case <-h.quit:
+		return false
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// IngestItem is one vector sent for ingestion.
+type IngestItem struct {
+	Vec []float64 `json:"vec,omitempty"`
+	// TTL is the payload lifetime in seconds. Zero or absent means the
+	// payload never expires.
+	TTL float64 `json:"ttl,omitempty"`
+	// Type, if set, names a payload type registered with the handler's
+	// PayloadFactory, which builds the payload from Data. The payload
+	// then provides its own vector and expiry, so Vec and TTL are ignored.
+	Type string `json:"type,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// NewWireItem returns the IngestItem carrying p, for ingestion by a handler
+// whose PayloadFactory knows p's type.
+func NewWireItem(p common.WirePayload) (IngestItem, error) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return IngestItem{}, err
+	}
+	return IngestItem{Type: p.TypeName(), Data: data}, nil
+}
+
+// BatchIngestResponse is the reply to POST /ingest/batch. OK[i] reports
+// whether item i was accepted; Rejected lists the indexes that were not.
+type BatchIngestResponse struct {
+	OK       []bool `json:"ok"`
+	Rejected []int  `json:"rejected"`
+}
+
+// ingestBatch handles POST /ingest/batch: the body is a JSON array of
+// IngestItem, each routed to its nearest centroid independently, so one bad
+// vector (nil, or of the wrong dimension) doesn't fail the others.
+func (h *Handler) ingestBatch(w http.ResponseWriter, r *http.Request) {
+	var items []IngestItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, "malformed batch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := BatchIngestResponse{OK: make([]bool, len(items)), Rejected: []int{}}
+	h.mu.Lock()
+	for i, item := range items {
+		resp.OK[i] = h.ingest(item)
+		if !resp.OK[i] {
+			resp.Rejected = append(resp.Rejected, i)
+		}
+	}
+	h.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+// StreamIngestResponse is the reply to POST /ingest/stream, sent once the
+// request body has been read to the end and every item routed. Lines are
+// numbered from 1; blank lines are skipped but still counted.
+type StreamIngestResponse struct {
+	Accepted int `json:"accepted"`
+	// Rejected lists the lines holding well-formed items the model did
+	// not accept.
+	Rejected []int `json:"rejected"`
+	// Malformed lists the lines that aren't a JSON IngestItem.
+	Malformed []int `json:"malformed"`
+}
+
+// ingestStream handles POST /ingest/stream: the body is newline-delimited
+// JSON, one IngestItem per line, and items are queued for routing as they
+// are read, so unbounded streams never need buffering. A malformed line is
+// reported without aborting the stream.
+func (h *Handler) ingestStream(w http.ResponseWriter, r *http.Request) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		resp = StreamIngestResponse{Rejected: []int{}, Malformed: []int{}}
+	)
+	reject := func(line int) {
+		mu.Lock()
+		resp.Rejected = append(resp.Rejected, line)
+		mu.Unlock()
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, maxStreamLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var item IngestItem
+		if err := json.Unmarshal(b, &item); err != nil {
+			resp.Malformed = append(resp.Malformed, line)
+			continue
+		}
+
+		n := line
+		wg.Add(1)
+		job := ingestJob{item: item, done: func(ok bool) {
+			defer wg.Done()
+			if !ok {
+				reject(n)
+				return
+			}
+			mu.Lock()
+			resp.Accepted++
+			mu.Unlock()
+		}}
+		if !h.enqueue(job) {
+			wg.Done()
+			reject(n)
+		}
+	}
+	wg.Wait()
+	if err := scanner.Err(); err != nil {
+		http.Error(w, "reading stream: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Items are routed by a single worker in queue order, but rejections
+	// from a concurrent Close may interleave.
+	slices.Sort(resp.Rejected)
+	writeJSON(w, resp)
+}
+
+// ingest routes item into the model. The caller must hold h.mu.
+func (h *Handler) ingest(item IngestItem) bool {
+	if item.Type != "" {
+		p, ok := h.factory.New(item.Type, item.Data)
+		return ok && h.model.AddPayload(p)
+	}
+	if item.Vec == nil || item.TTL < 0 {
+		return false
+	}
+	ttl := time.Duration(item.TTL * float64(time.Second))
+	return h.model.AddPayload(newTTLPayload(item.Vec, ttl, h.clock))
+}
+
+// writeJSON writes v as a JSON response body.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
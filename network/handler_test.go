@@ -0,0 +1,265 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/kmeans"
+)
+
+// newTestHandler serves a two-cluster Euclidean model over 2D vectors,
+// with a clock fixed at now. The handler is closed when the test ends.
+func newTestHandler(t *testing.T, now time.Time) (*Handler, *kmeans.KMeans) {
+	t.Helper()
+	return newTestHandlerArgs(t, NewHandlerArgs{Clock: func() time.Time { return now }})
+}
+
+// newTestHandlerArgs is newTestHandler with args, whose Model is set to
+// the test model.
+func newTestHandlerArgs(t *testing.T, args NewHandlerArgs) (*Handler, *kmeans.KMeans) {
+	t.Helper()
+	km := kmeans.NewKMeans(kmeans.NewKMeansArgs{})
+	vecs := [][]float64{{0, 0}, {0, 1}, {10, 10}, {10, 11}}
+	if !km.Fit(vecs, kmeans.FitArgs{K: 2, MaxIter: 100}) {
+		t.Fatal("fit failed")
+	}
+	args.Model = km
+	h, ok := NewHandler(args)
+	if !ok {
+		t.Fatal("failed to create handler")
+	}
+	t.Cleanup(h.Close)
+	return h, km
+}
+
+// lenDP returns the total number of payloads held by km.
+func lenDP(km *kmeans.KMeans) int {
+	n := 0
+	km.Iterate(func(_ int, c *kmeans.Centroid) bool {
+		n += c.LenDP()
+		return true
+	})
+	return n
+}
+
+func TestNewHandlerInvalid(t *testing.T) {
+	if _, ok := NewHandler(NewHandlerArgs{}); ok {
+		t.Error("created handler without model")
+	}
+	km := kmeans.NewKMeans(kmeans.NewKMeansArgs{})
+	if _, ok := NewHandler(NewHandlerArgs{Model: km, IngestQueueSize: -1}); ok {
+		t.Error("created handler with negative queue size")
+	}
+}
+
+func TestHandlerRoutesByMethod(t *testing.T) {
+	h, _ := newTestHandler(t, time.Unix(0, 0))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query", nil))
+	// A 404 means the method patterns were taken as literal paths, as
+	// they are when the main module declares a go version before 1.22.
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /query: got status %d, want %d; method routing needs go 1.22",
+			rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestIngestBatch(t *testing.T) {
+	h, km := newTestHandler(t, time.Unix(0, 0))
+	before := lenDP(km)
+
+	body := `[
+		{"vec": [1, 1]},
+		{"vec": [1]},
+		{"vec": [9, 9], "ttl": 60},
+		{},
+		{"vec": [0, 0, 0]},
+		{"vec": [1, 0], "ttl": -1}
+	]`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp BatchIngestResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := BatchIngestResponse{
+		OK:       []bool{true, false, true, false, false, false},
+		Rejected: []int{1, 3, 4, 5},
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %+v, want %+v", resp, want)
+	}
+	if got := lenDP(km); got != before+2 {
+		t.Errorf("model holds %d payloads, want %d", got, before+2)
+	}
+}
+
+func TestIngestBatchInvalid(t *testing.T) {
+	h, _ := newTestHandler(t, time.Unix(0, 0))
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, `{"vec": [1, 1]}`, http.StatusBadRequest},
+		{http.MethodPost, `[{"vec": [1, 1]}`, http.StatusBadRequest},
+		{http.MethodGet, ``, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/ingest/batch", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %q: got status %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestTTLPayloadExpired(t *testing.T) {
+	now := time.Unix(0, 0)
+	clock := func() time.Time { return now }
+	p := newTTLPayload([]float64{1}, time.Minute, clock)
+	forever := newTTLPayload([]float64{1}, 0, clock)
+
+	if p.Expired() || forever.Expired() {
+		t.Fatal("expired immediately")
+	}
+	now = now.Add(time.Minute)
+	if !p.Expired() {
+		t.Error("not expired after TTL")
+	}
+	if forever.Expired() {
+		t.Error("payload without TTL expired")
+	}
+}
+
+func TestIngestStream(t *testing.T) {
+	// A tiny queue makes the reader wait on the worker most of the time.
+	h, km := newTestHandlerArgs(t, NewHandlerArgs{IngestQueueSize: 2})
+	before := lenDP(km)
+
+	var body strings.Builder
+	const n = 1000
+	var malformed, rejected []int
+	for i := 1; i <= n; i++ {
+		switch {
+		case i%97 == 0:
+			body.WriteString("{\"vec\": [1, \n")
+			malformed = append(malformed, i)
+		case i%101 == 0:
+			body.WriteString(`{"vec": [1, 2, 3]}` + "\n")
+			rejected = append(rejected, i)
+		default:
+			fmt.Fprintf(&body, `{"vec": [%d, %d]}`+"\n", i%12, i%7)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingest/stream", strings.NewReader(body.String())))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp StreamIngestResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := StreamIngestResponse{
+		Accepted:  n - len(malformed) - len(rejected),
+		Rejected:  rejected,
+		Malformed: malformed,
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %+v, want %+v", resp, want)
+	}
+	if got := lenDP(km); got != before+want.Accepted {
+		t.Errorf("model holds %d payloads, want %d", got, before+want.Accepted)
+	}
+}
+
+func TestIngestStreamClosed(t *testing.T) {
+	h, km := newTestHandler(t, time.Unix(0, 0))
+	before := lenDP(km)
+	h.Close()
+
+	body := `{"vec": [1, 1]}` + "\n" + `{"vec": [2, 2]}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingest/stream", strings.NewReader(body)))
+
+	var resp StreamIngestResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Accepted != 0 || !reflect.DeepEqual(resp.Rejected, []int{1, 2}) {
+		t.Errorf("got %+v, want both lines rejected", resp)
+	}
+	if got := lenDP(km); got != before {
+		t.Errorf("model holds %d payloads, want %d", got, before)
+	}
+}
+
+// docPayload is a custom payload type for round-trip tests.
+type docPayload struct {
+	V     []float64 `json:"v"`
+	Title string    `json:"title"`
+}
+
+func (p *docPayload) Vec() []float64                 { return p.V }
+func (p *docPayload) Expired() bool                  { return false }
+func (p *docPayload) TypeName() string               { return "doc" }
+func (p *docPayload) MarshalBinary() ([]byte, error) { return json.Marshal(p) }
+
+func newDocPayload(data []byte) common.PayloadContainer {
+	var p docPayload
+	if json.Unmarshal(data, &p) != nil {
+		return nil
+	}
+	return &p
+}
+
+func TestPayloadFactoryRoundTrip(t *testing.T) {
+	factory := &common.PayloadFactory{}
+	factory.Register("doc", newDocPayload)
+	h, _ := newTestHandlerArgs(t, NewHandlerArgs{PayloadFactory: factory})
+
+	doc := &docPayload{V: []float64{5, 5}, Title: "hello"}
+	item, err := NewWireItem(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := IngestItem{Type: "other", Data: item.Data}
+	body, _ := json.Marshal([]IngestItem{item, unknown})
+	rec := postJSON(h, "/ingest/batch", string(body))
+	var ingested BatchIngestResponse
+	if err := json.NewDecoder(rec.Body).Decode(&ingested); err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, false}; !reflect.DeepEqual(ingested.OK, want) {
+		t.Fatalf("got ingest status %v, want %v", ingested.OK, want)
+	}
+
+	rec = postJSON(h, "/query", `{"vec": [5, 5], "k": 1, "probes": 2}`)
+	var results []QueryResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	p, ok := results[0].Payload(factory)
+	if !ok {
+		t.Fatalf("failed to rebuild payload from %+v", results[0])
+	}
+	if !reflect.DeepEqual(p, doc) {
+		t.Errorf("got %+v, want %+v", p, doc)
+	}
+}
@@ -0,0 +1,30 @@
+package network
+
+import "time"
+
+// ttlPayload is the payload the handler stores for ingested vectors. It
+// expires once its TTL has passed on the handler clock; a zero expiry means
+// it never does.
+type ttlPayload struct {
+	vec     []float64
+	created time.Time
+	expires time.Time
+	clock   func() time.Time
+}
+
+// newTTLPayload creates a payload for vec created now on clock. A ttl of
+// zero or less means the payload never expires.
+func newTTLPayload(vec []float64, ttl time.Duration, clock func() time.Time) *ttlPayload {
+	p := &ttlPayload{vec: vec, created: clock(), clock: clock}
+	if ttl > 0 {
+		p.expires = p.created.Add(ttl)
+	}
+	return p
+}
+
+func (p *ttlPayload) Vec() []float64     { return p.vec }
+func (p *ttlPayload) Created() time.Time { return p.created }
+
+func (p *ttlPayload) Expired() bool {
+	return !p.expires.IsZero() && !p.clock().Before(p.expires)
+}
@@ -0,0 +1,143 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// QueryRequest is the body of POST /query and POST /query/stream.
+type QueryRequest struct {
+	Vec []float64 `json:"vec"`
+	// K is the maximum number of results; at least 1.
+	K int `json:"k"`
+	// Probes is the number of centroids nearest to Vec to search.
+	// Defaults to 1.
+	Probes int `json:"probes,omitempty"`
+	// Metric ranks the results, by any name mathutils.MetricByName
+	// accepts, such as "euclidean" or "cosine". Defaults to the model
+	// metric, which also picks the probed centroids either way.
+	Metric string `json:"metric,omitempty"`
+}
+
+// QueryResult is one payload matching a query. Score is the negated
+// distance to the query vector under the query metric, so higher is better.
+// Payloads implementing common.WirePayload also carry their type name and
+// encoding; see Payload.
+type QueryResult struct {
+	Vec   []float64 `json:"vec"`
+	Score float64   `json:"score"`
+	Type  string    `json:"type,omitempty"`
+	Data  []byte    `json:"data,omitempty"`
+}
+
+// Payload rebuilds the payload behind r with factory. Returns false if r
+// has no type or factory can't build it.
+func (r QueryResult) Payload(factory *common.PayloadFactory) (common.PayloadContainer, bool) {
+	if r.Type == "" {
+		return nil, false
+	}
+	return factory.New(r.Type, r.Data)
+}
+
+// query is a decoded QueryRequest with its metric resolved; a nil metric
+// means the model metric.
+type query struct {
+	QueryRequest
+	metric mathutils.Metric
+}
+
+// decodeQuery reads a QueryRequest from r, applying defaults. On failure it
+// writes a 400 to w and returns false.
+func decodeQuery(w http.ResponseWriter, r *http.Request) (query, bool) {
+	var q query
+	if err := json.NewDecoder(r.Body).Decode(&q.QueryRequest); err != nil {
+		http.Error(w, "malformed query: "+err.Error(), http.StatusBadRequest)
+		return q, false
+	}
+	if q.Probes == 0 {
+		q.Probes = 1
+	}
+	if q.Vec == nil || q.K < 1 || q.Probes < 0 {
+		http.Error(w, "query needs a vec, k >= 1 and probes >= 0", http.StatusBadRequest)
+		return q, false
+	}
+	if q.Metric != "" {
+		m, ok := mathutils.MetricByName(q.Metric)
+		if !ok {
+			http.Error(w, "unknown metric "+strconv.Quote(q.Metric), http.StatusBadRequest)
+			return q, false
+		}
+		q.metric = m
+	}
+	return q, true
+}
+
+// results returns a generator yielding up to q.K results best first. The
+// candidates are collected under the lock, but ranked lazily as the
+// generator is drained, so callers needn't hold the lock while responding.
+func (h *Handler) results(q query) func() (QueryResult, bool) {
+	h.mu.Lock()
+	next := h.model.QueryBestFirst(q.Vec, q.Probes, q.metric)
+	h.mu.Unlock()
+
+	n := 0
+	return func() (QueryResult, bool) {
+		if n == q.K {
+			return QueryResult{}, false
+		}
+		p, d, ok := next()
+		if !ok {
+			return QueryResult{}, false
+		}
+		n++
+		res := QueryResult{Vec: p.Vec(), Score: -d}
+		if wp, ok := common.Unwrap(p).(common.WirePayload); ok {
+			if data, err := wp.MarshalBinary(); err == nil {
+				res.Type, res.Data = wp.TypeName(), data
+			}
+		}
+		return res, true
+	}
+}
+
+// query handles POST /query, replying with a JSON array of QueryResult,
+// best first.
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
+	q, ok := decodeQuery(w, r)
+	if !ok {
+		return
+	}
+	results := []QueryResult{}
+	next := h.results(q)
+	for res, ok := next(); ok; res, ok = next() {
+		results = append(results, res)
+	}
+	writeJSON(w, results)
+}
+
+// queryStream handles POST /query/stream, replying with newline-delimited
+// QueryResult JSON, best first. Each result is flushed as soon as it is
+// ranked, so clients asking for a large K see the best matches without
+// waiting for the rest.
+func (h *Handler) queryStream(w http.ResponseWriter, r *http.Request) {
+	q, ok := decodeQuery(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	next := h.results(q)
+	for res, ok := next(); ok; res, ok = next() {
+		if err := enc.Encode(res); err != nil {
+			return // The client went away.
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
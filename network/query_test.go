@@ -0,0 +1,128 @@
+package network
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postJSON posts body to path on h and returns the recorded response.
+func postJSON(h http.Handler, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestQuery(t *testing.T) {
+	h, _ := newTestHandler(t, time.Unix(0, 0))
+
+	rec := postJSON(h, "/query", `{"vec": [0, 2], "k": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var got []QueryResult
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []QueryResult{{Vec: []float64{0, 1}, Score: -1}, {Vec: []float64{0, 0}, Score: -2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQueryMetric(t *testing.T) {
+	h, _ := newTestHandler(t, time.Unix(0, 0))
+	h.mu.Lock()
+	h.ingest(IngestItem{Vec: []float64{10, 0}}) // Same direction as the query.
+	h.ingest(IngestItem{Vec: []float64{2, 2}})  // Closer to the query.
+	h.mu.Unlock()
+
+	best := func(metric string) []float64 {
+		t.Helper()
+		body := `{"vec": [3, 0], "k": 1, "probes": 2, "metric": "` + metric + `"}`
+		rec := postJSON(h, "/query", body)
+		var results []QueryResult
+		if err := json.NewDecoder(rec.Body).Decode(&results); err != nil || len(results) != 1 {
+			t.Fatalf("%s: got %v, %v", metric, results, err)
+		}
+		return results[0].Vec
+	}
+	if got, want := best("euclidean"), []float64{2, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("euclidean: got %v, want %v", got, want)
+	}
+	if got, want := best("cosine"), []float64{10, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cosine: got %v, want %v", got, want)
+	}
+}
+
+func TestQueryInvalid(t *testing.T) {
+	h, _ := newTestHandler(t, time.Unix(0, 0))
+	for _, path := range []string{"/query", "/query/stream"} {
+		for _, body := range []string{
+			`{"vec": [0, 2]`,
+			`{"k": 2}`,
+			`{"vec": [0, 2], "k": 0}`,
+			`{"vec": [0, 2], "k": 1, "probes": -1}`,
+			`{"vec": [0, 2], "k": 1, "metric": "hamming"}`,
+		} {
+			if rec := postJSON(h, path, body); rec.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: got status %d, want %d", path, body, rec.Code, http.StatusBadRequest)
+			}
+		}
+	}
+}
+
+func TestQueryStream(t *testing.T) {
+	h, km := newTestHandler(t, time.Unix(0, 0))
+	h.mu.Lock()
+	for _, v := range uniformTestVecs(500) {
+		h.ingest(IngestItem{Vec: v})
+	}
+	h.mu.Unlock()
+	total := lenDP(km)
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/query/stream", "application/json",
+		strings.NewReader(`{"vec": [5, 5], "k": 10000, "probes": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("got content type %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	n, prev := 0, 0.
+	for scanner.Scan() {
+		var res QueryResult
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			t.Fatalf("line %d: %v", n+1, err)
+		}
+		if n > 0 && res.Score > prev {
+			t.Fatalf("line %d: score %v after %v", n+1, res.Score, prev)
+		}
+		n, prev = n+1, res.Score
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != total {
+		t.Errorf("streamed %d results, want %d", n, total)
+	}
+}
+
+// uniformTestVecs returns n deterministic 2D vectors spread over [0, 11).
+func uniformTestVecs(n int) [][]float64 {
+	vecs := make([][]float64, n)
+	for i := range vecs {
+		vecs[i] = []float64{float64(i*7%110) / 10, float64(i*13%110) / 10}
+	}
+	return vecs
+}
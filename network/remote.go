@@ -0,0 +1,191 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/kmeans"
+)
+
+// RemoteReceiver is a kmeans.PayloadReceiver standing for a remote node
+// served by a Handler, so payloads can be distributed across the network
+// (see kmeans.Centroid.DistributePayload). Payloads are delivered through
+// the node's POST /ingest/batch endpoint. A RemoteReceiver is safe for
+// concurrent use.
+type RemoteReceiver struct {
+	addr       string
+	vec        []float64
+	client     *http.Client
+	maxRetries int
+	baseDelay  time.Duration
+	sleep      func(time.Duration)
+	clock      func() time.Time
+
+	// Circuit breaker state; see NewRemoteReceiverArgs.BreakerThreshold.
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+var (
+	_ kmeans.PayloadReceiver = (*RemoteReceiver)(nil)
+	_ kmeans.HealthReporter  = (*RemoteReceiver)(nil)
+)
+
+// NewRemoteReceiverArgs is the argument set for NewRemoteReceiver.
+type NewRemoteReceiverArgs struct {
+	// Addr is the base URL of the remote node, e.g. "http://host:8080".
+	// Required.
+	Addr string
+	// Vec is the vector the remote node is known by when payloads are
+	// routed to the nearest receiver. Required; it is copied.
+	Vec []float64
+	// Client sends the requests. Defaults to http.DefaultClient; set one
+	// with a timeout for production use.
+	Client *http.Client
+	// MaxRetries is how many times a delivery failing with a transient
+	// error (a transport error or a 5xx status) is retried before
+	// AddPayload gives up. Zero, the default, means no retries.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry. Each further retry
+	// waits twice as long as the one before.
+	BaseDelay time.Duration
+	// BreakerThreshold, if positive, enables a circuit breaker: after
+	// this many consecutive AddPayload calls failing with transient
+	// errors (after retries), the breaker opens, and AddPayload fails
+	// fast and Healthy reports false for BreakerCooldown, so a dead node
+	// isn't hammered and DistributePayload routes around it. After the
+	// cooldown the breaker half-opens, letting calls through; one success
+	// closes it, while a failure opens it for another cooldown.
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open.
+	BreakerCooldown time.Duration
+	// Clock times the breaker cooldown. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// NewRemoteReceiver creates a RemoteReceiver from args. Returns false if
+// Addr is empty, Vec is nil, or MaxRetries, BaseDelay, BreakerThreshold or
+// BreakerCooldown is negative.
+func NewRemoteReceiver(args NewRemoteReceiverArgs) (*RemoteReceiver, bool) {
+	if args.Addr == "" || args.Vec == nil || args.MaxRetries < 0 || args.BaseDelay < 0 {
+		return nil, false
+	}
+	if args.BreakerThreshold < 0 || args.BreakerCooldown < 0 {
+		return nil, false
+	}
+	if args.Client == nil {
+		args.Client = http.DefaultClient
+	}
+	if args.Clock == nil {
+		args.Clock = time.Now
+	}
+	return &RemoteReceiver{
+		addr:       strings.TrimSuffix(args.Addr, "/"),
+		vec:        append([]float64(nil), args.Vec...),
+		client:     args.Client,
+		maxRetries: args.MaxRetries,
+		baseDelay:  args.BaseDelay,
+		sleep:      time.Sleep,
+		clock:      args.Clock,
+		threshold:  args.BreakerThreshold,
+		cooldown:   args.BreakerCooldown,
+	}, true
+}
+
+// Vec returns the vector the remote node is known by.
+func (r *RemoteReceiver) Vec() []float64 {
+	return r.vec
+}
+
+// Healthy reports whether the circuit breaker lets deliveries through. It is
+// always true without a breaker (see NewRemoteReceiverArgs.BreakerThreshold).
+func (r *RemoteReceiver) Healthy() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.healthy()
+}
+
+// healthy is Healthy for callers holding r.mu.
+func (r *RemoteReceiver) healthy() bool {
+	return r.threshold == 0 || r.failures < r.threshold || !r.clock().Before(r.openUntil)
+}
+
+// record updates the circuit breaker with the outcome of a delivery: down
+// is true if the node couldn't be reached.
+func (r *RemoteReceiver) record(down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !down {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.threshold > 0 && r.failures >= r.threshold {
+		r.openUntil = r.clock().Add(r.cooldown)
+	}
+}
+
+// AddPayload delivers p to the remote node, retrying transient failures
+// with exponential backoff (see NewRemoteReceiverArgs.MaxRetries). Payloads
+// implementing common.WirePayload are sent typed, so the node needs their
+// type in its PayloadFactory; others are sent as bare vectors, dropping any
+// expiry. Returns false if p is nil or expired, if the circuit breaker is
+// open, if the node rejects p, or if every attempt failed. A reply the
+// receiver can't read is not retried, since the node may have taken p
+// already, and also gives false.
+func (r *RemoteReceiver) AddPayload(p common.PayloadContainer) bool {
+	if p == nil || p.Expired() || !r.Healthy() {
+		return false
+	}
+	item := IngestItem{Vec: p.Vec()}
+	if wp, ok := common.Unwrap(p).(common.WirePayload); ok {
+		var err error
+		if item, err = NewWireItem(wp); err != nil {
+			return false
+		}
+	}
+	body, err := json.Marshal([]IngestItem{item})
+	if err != nil {
+		return false
+	}
+
+	delay := r.baseDelay
+	for attempt := 0; ; attempt++ {
+		ok, transient := r.deliver(body)
+		if ok || !transient || attempt == r.maxRetries {
+			r.record(transient)
+			return ok
+		}
+		r.sleep(delay)
+		delay *= 2
+	}
+}
+
+// deliver makes one attempt at posting a single-item batch body. It reports
+// whether the item was accepted and, if not, whether the failure may be
+// transient and worth retrying.
+func (r *RemoteReceiver) deliver(body []byte) (ok, transient bool) {
+	resp, err := r.client.Post(r.addr+"/ingest/batch", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, true
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, resp.StatusCode >= 500
+	}
+	var batch BatchIngestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		// The node has most likely taken the item already; posting it
+		// again could duplicate it.
+		return false, false
+	}
+	return len(batch.OK) == 1 && batch.OK[0], false
+}
@@ -0,0 +1,199 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyHandler fails the first failures requests, and every request while
+// down is set, with a 503, passing the rest to next. It counts requests.
+type flakyHandler struct {
+	next     http.Handler
+	failures int32
+	down     atomic.Bool
+	requests atomic.Int32
+}
+
+func (f *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.requests.Add(1) <= f.failures || f.down.Load() {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+// newTestRemote serves a test handler behind a flakyHandler failing
+// failures times, and returns a receiver for it configured by args.
+func newTestRemote(
+	t *testing.T,
+	failures int32,
+	args NewRemoteReceiverArgs,
+) (*RemoteReceiver, *flakyHandler, func() int) {
+	t.Helper()
+	h, km := newTestHandler(t, time.Unix(0, 0))
+	flaky := &flakyHandler{next: h, failures: failures}
+	srv := httptest.NewServer(flaky)
+	t.Cleanup(srv.Close)
+
+	args.Addr, args.Vec = srv.URL, []float64{0, 0}
+	r, ok := NewRemoteReceiver(args)
+	if !ok {
+		t.Fatal("failed to create receiver")
+	}
+	r.sleep = func(time.Duration) {} // Tests needn't wait out the backoff.
+	before := lenDP(km)
+	return r, flaky, func() int { return lenDP(km) - before }
+}
+
+func TestRemoteReceiverRetry(t *testing.T) {
+	r, flaky, delivered := newTestRemote(t, 2, NewRemoteReceiverArgs{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+	})
+	var slept []time.Duration
+	r.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	if !r.AddPayload(&testPayload{vec: []float64{1, 1}}) {
+		t.Fatal("payload not delivered")
+	}
+	if got := delivered(); got != 1 {
+		t.Errorf("remote model gained %d payloads, want 1", got)
+	}
+	if got := flaky.requests.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+	if want := []time.Duration{time.Millisecond, 2 * time.Millisecond}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("got backoff %v, want %v", slept, want)
+	}
+}
+
+func TestRemoteReceiverGiveUp(t *testing.T) {
+	r, flaky, delivered := newTestRemote(t, 5, NewRemoteReceiverArgs{MaxRetries: 2})
+	if r.AddPayload(&testPayload{vec: []float64{1, 1}}) {
+		t.Error("delivered through a failing node")
+	}
+	if got := flaky.requests.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+	if delivered() != 0 {
+		t.Error("payload delivered")
+	}
+}
+
+func TestRemoteReceiverRejected(t *testing.T) {
+	r, flaky, _ := newTestRemote(t, 0, NewRemoteReceiverArgs{MaxRetries: 3})
+	if r.AddPayload(&testPayload{vec: []float64{1, 1, 1}}) {
+		t.Error("delivered payload of wrong dimension")
+	}
+	if got := flaky.requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1: rejections must not be retried", got)
+	}
+	if r.AddPayload(&testPayload{vec: []float64{1, 1}, expired: true}) || r.AddPayload(nil) {
+		t.Error("delivered invalid payload")
+	}
+}
+
+func TestRemoteReceiverGarbageReply(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("not json"))
+	}))
+	t.Cleanup(srv.Close)
+	r, ok := NewRemoteReceiver(NewRemoteReceiverArgs{Addr: srv.URL, Vec: []float64{0, 0}, MaxRetries: 3})
+	if !ok {
+		t.Fatal("failed to create receiver")
+	}
+	r.sleep = func(time.Duration) {}
+
+	if r.AddPayload(&testPayload{vec: []float64{1, 1}}) {
+		t.Error("unreadable reply counted as delivered")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1: the item may have been taken", got)
+	}
+	if !r.Healthy() {
+		t.Error("reachable node marked down")
+	}
+}
+
+func TestRemoteReceiverCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	r, flaky, delivered := newTestRemote(t, 0, NewRemoteReceiverArgs{
+		MaxRetries:       1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+		Clock:            func() time.Time { return now },
+	})
+	p := &testPayload{vec: []float64{1, 1}}
+
+	flaky.down.Store(true)
+	r.AddPayload(p)
+	if !r.Healthy() {
+		t.Fatal("breaker opened after one failure")
+	}
+	r.AddPayload(p)
+	if r.Healthy() {
+		t.Fatal("breaker still closed after two failures")
+	}
+
+	// Open: fail fast without reaching the node, even once it is back.
+	flaky.down.Store(false)
+	requests := flaky.requests.Load()
+	now = now.Add(time.Minute - time.Second)
+	if r.AddPayload(p) {
+		t.Error("delivered through an open breaker")
+	}
+	if got := flaky.requests.Load(); got != requests {
+		t.Errorf("open breaker made %d requests", got-requests)
+	}
+
+	// Half-open: a failed trial reopens the breaker for a full cooldown.
+	now = now.Add(time.Second)
+	flaky.down.Store(true)
+	if !r.Healthy() {
+		t.Fatal("breaker not half-open after cooldown")
+	}
+	r.AddPayload(p)
+	if r.Healthy() {
+		t.Fatal("breaker not reopened by failed trial")
+	}
+
+	// Half-open again with the node back: a success closes the breaker.
+	now = now.Add(time.Minute)
+	flaky.down.Store(false)
+	if !r.AddPayload(p) {
+		t.Fatal("payload not delivered after recovery")
+	}
+	if !r.Healthy() || delivered() != 1 {
+		t.Errorf("healthy: %v, delivered: %d; want true, 1", r.Healthy(), delivered())
+	}
+}
+
+func TestNewRemoteReceiverInvalid(t *testing.T) {
+	for _, args := range []NewRemoteReceiverArgs{
+		{Vec: []float64{0}},
+		{Addr: "http://x"},
+		{Addr: "http://x", Vec: []float64{0}, MaxRetries: -1},
+		{Addr: "http://x", Vec: []float64{0}, BaseDelay: -1},
+		{Addr: "http://x", Vec: []float64{0}, BreakerThreshold: -1},
+		{Addr: "http://x", Vec: []float64{0}, BreakerCooldown: -1},
+	} {
+		if _, ok := NewRemoteReceiver(args); ok {
+			t.Errorf("%+v: expected failure", args)
+		}
+	}
+}
+
+// testPayload is a minimal common.PayloadContainer for tests.
+type testPayload struct {
+	vec     []float64
+	expired bool
+}
+
+func (p *testPayload) Vec() []float64 { return p.vec }
+func (p *testPayload) Expired() bool  { return p.expired }
@@ -0,0 +1,132 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crunchypi/net-means/kmeans"
+)
+
+// CentroidSummary describes one centroid of a served model. GET /centroids
+// replies with a JSON array of them, indexed like kmeans.KMeans.Iterate.
+// Clients use them to decide which nodes to send queries and payloads to
+// without transferring the payloads themselves.
+type CentroidSummary struct {
+	ID  string    `json:"id"`
+	Vec []float64 `json:"vec"`
+	// Count is the number of payloads held, expired ones included.
+	Count int `json:"count"`
+}
+
+// centroids handles GET /centroids.
+func (h *Handler) centroids(w http.ResponseWriter, r *http.Request) {
+	summaries := []CentroidSummary{}
+	h.mu.Lock()
+	h.model.Iterate(func(_ int, c *kmeans.Centroid) bool {
+		summaries = append(summaries, CentroidSummary{ID: c.ID(), Vec: c.VecCopy(), Count: c.LenDP()})
+		return true
+	})
+	h.mu.Unlock()
+	writeJSON(w, summaries)
+}
+
+// SummaryClient fetches the centroid summaries of a remote node served by a
+// Handler. It can serve them from a cache of bounded staleness (see
+// NewSummaryClientArgs.MaxStaleness), trading freshness for the latency of
+// a round-trip. A SummaryClient is safe for concurrent use; concurrent
+// fetches are serialized, so a cache that just went stale is refreshed
+// once rather than by every caller.
+type SummaryClient struct {
+	addr         string
+	client       *http.Client
+	maxStaleness time.Duration
+	clock        func() time.Time
+
+	mu      sync.Mutex
+	cached  []CentroidSummary
+	fetched time.Time
+}
+
+// NewSummaryClientArgs is the argument set for NewSummaryClient.
+type NewSummaryClientArgs struct {
+	// Addr is the base URL of the remote node, e.g. "http://host:8080".
+	// Required.
+	Addr string
+	// Client sends the requests. Defaults to http.DefaultClient; set one
+	// with a timeout for production use.
+	Client *http.Client
+	// MaxStaleness, if positive, lets Summaries answer from the last
+	// fetch while it is younger than this, instead of fetching again.
+	// Zero, the default, means every call fetches live.
+	MaxStaleness time.Duration
+	// Clock ages the cache. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// NewSummaryClient creates a SummaryClient from args. Returns false if Addr
+// is empty or MaxStaleness is negative.
+func NewSummaryClient(args NewSummaryClientArgs) (*SummaryClient, bool) {
+	if args.Addr == "" || args.MaxStaleness < 0 {
+		return nil, false
+	}
+	if args.Client == nil {
+		args.Client = http.DefaultClient
+	}
+	if args.Clock == nil {
+		args.Clock = time.Now
+	}
+	return &SummaryClient{
+		addr:         strings.TrimSuffix(args.Addr, "/"),
+		client:       args.Client,
+		maxStaleness: args.MaxStaleness,
+		clock:        args.Clock,
+	}, true
+}
+
+// Summaries returns the centroid summaries of the remote node. They come
+// from the cache if it is younger than MaxStaleness, and are fetched live
+// (and cached) otherwise. A failed fetch leaves the cache as it was. The
+// returned summaries may be shared with other callers and must not be
+// modified.
+func (s *SummaryClient) Summaries() ([]CentroidSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && s.clock().Sub(s.fetched) < s.maxStaleness {
+		return s.cached, nil
+	}
+	return s.fetch()
+}
+
+// Fetch is Summaries bypassing the cache: it always fetches live, and
+// caches the result.
+func (s *SummaryClient) Fetch() ([]CentroidSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetch()
+}
+
+// fetch is Fetch for callers holding s.mu.
+func (s *SummaryClient) fetch() ([]CentroidSummary, error) {
+	fetched := s.clock()
+	resp, err := s.client.Get(s.addr + "/centroids")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("network: fetching centroids: %s", resp.Status)
+	}
+	var summaries []CentroidSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+		return nil, fmt.Errorf("network: fetching centroids: %w", err)
+	}
+	if summaries == nil {
+		summaries = []CentroidSummary{}
+	}
+	s.cached, s.fetched = summaries, fetched
+	return summaries, nil
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestSummaryClient serves a test handler behind a request-counting
+// flakyHandler, and returns a summary client for it configured by args.
+func newTestSummaryClient(t *testing.T, args NewSummaryClientArgs) (*SummaryClient, *flakyHandler) {
+	t.Helper()
+	h, _ := newTestHandler(t, time.Unix(0, 0))
+	flaky := &flakyHandler{next: h}
+	srv := httptest.NewServer(flaky)
+	t.Cleanup(srv.Close)
+
+	args.Addr = srv.URL
+	s, ok := NewSummaryClient(args)
+	if !ok {
+		t.Fatal("failed to create summary client")
+	}
+	return s, flaky
+}
+
+func TestNewSummaryClientInvalid(t *testing.T) {
+	if _, ok := NewSummaryClient(NewSummaryClientArgs{}); ok {
+		t.Error("created client without address")
+	}
+	if _, ok := NewSummaryClient(NewSummaryClientArgs{Addr: "http://x", MaxStaleness: -1}); ok {
+		t.Error("created client with negative staleness")
+	}
+}
+
+func TestSummaryClient(t *testing.T) {
+	now := time.Unix(0, 0)
+	s, flaky := newTestSummaryClient(t, NewSummaryClientArgs{
+		MaxStaleness: time.Second,
+		Clock:        func() time.Time { return now },
+	})
+	summaries, err := s.Summaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+	for _, sum := range summaries {
+		if sum.ID == "" || len(sum.Vec) != 2 || sum.Count != 2 {
+			t.Errorf("unexpected summary %+v", sum)
+		}
+	}
+
+	steps := []struct {
+		advance  time.Duration
+		requests int32
+	}{
+		{0, 1},
+		{999 * time.Millisecond, 1},
+		{time.Millisecond, 2},
+		{500 * time.Millisecond, 2},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if _, err := s.Summaries(); err != nil {
+			t.Fatal(err)
+		}
+		if got := flaky.requests.Load(); got != step.requests {
+			t.Errorf("after %v: got %d requests, want %d", step.advance, got, step.requests)
+		}
+	}
+
+	if _, err := s.Fetch(); err != nil || flaky.requests.Load() != 3 {
+		t.Errorf("fetch: got %d requests, %v; want a live fetch", flaky.requests.Load(), err)
+	}
+}
+
+func TestSummaryClientLive(t *testing.T) {
+	s, flaky := newTestSummaryClient(t, NewSummaryClientArgs{
+		Clock: func() time.Time { return time.Unix(0, 0) },
+	})
+	for i := 0; i < 3; i++ {
+		s.Summaries()
+	}
+	if got := flaky.requests.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestSummaryClientFetchError(t *testing.T) {
+	now := time.Unix(0, 0)
+	s, flaky := newTestSummaryClient(t, NewSummaryClientArgs{
+		MaxStaleness: time.Second,
+		Clock:        func() time.Time { return now },
+	})
+	if _, err := s.Summaries(); err != nil {
+		t.Fatal(err)
+	}
+	flaky.down.Store(true)
+	now = now.Add(time.Second)
+	if _, err := s.Summaries(); err == nil {
+		t.Error("stale cache served while the node was down")
+	}
+	flaky.down.Store(false)
+	if _, err := s.Summaries(); err != nil || flaky.requests.Load() != 3 {
+		t.Errorf("got %d requests, %v; want a live retry", flaky.requests.Load(), err)
+	}
+}
@@ -0,0 +1,222 @@
+// Package searchutils implements k-nearest and k-furthest neighbour searches
+// over vector generators. All search funcs share the signature
+//
+//	func(target []float64, vecs func() ([]float64, bool), k int) []int
+//
+// where vecs yields candidate vectors until it returns false, and the result
+// holds the indexes (in generation order) of at most k matches, best first.
+// Candidates that cannot be compared with target, such as nil vectors or
+// vectors of a different dimension, are skipped but still consume an index.
+//
+// Candidates that score equally, duplicates included, keep their generation
+// order, and each one is a match of its own: duplicates are never merged,
+// so if k exceeds the number of distinct vectors the result still holds up
+// to k indexes, running short only when the comparable candidates do.
+package searchutils
+
+import (
+	"math"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// KNNCos returns the indexes of the k vectors most similar to target by
+// cosine similarity, most similar first.
+func KNNCos(target []float64, vecs func() ([]float64, bool), k int) []int {
+	return search(target, vecs, k, mathutils.CosineSimilarity, true)
+}
+
+// KNNCosInto is KNNCos writing the indexes into out, which is reused from
+// its start and only grown if its capacity is below k. Servers answering
+// many small queries can pass the same buffer every time to avoid
+// allocating per query. The result aliases out unless it had to grow.
+func KNNCosInto(target []float64, vecs func() ([]float64, bool), k int, out []int) []int {
+	return searchInto(target, vecs, k, mathutils.CosineSimilarity, true, out)
+}
+
+// KFNCos returns the indexes of the k vectors least similar to target by
+// cosine similarity, least similar first.
+func KFNCos(target []float64, vecs func() ([]float64, bool), k int) []int {
+	return search(target, vecs, k, mathutils.CosineSimilarity, false)
+}
+
+// CosineMode selects how KNNCosMode and KFNCosMode score cosine similarity,
+// which ranges over [-1, 1].
+type CosineMode int
+
+const (
+	// CosineSigned uses the similarity as is, so anti-parallel vectors
+	// (-1) are the least similar. This is what KNNCos and KFNCos do.
+	CosineSigned CosineMode = iota
+	// CosineAbsolute uses the absolute similarity, so anti-parallel
+	// vectors are as similar as parallel ones and orthogonal vectors the
+	// least similar. For domains where only the degree of alignment
+	// matters, not its sign.
+	CosineAbsolute
+	// CosineClamped clamps negative similarities to 0, so all vectors
+	// pointing away from the target tie with orthogonal ones.
+	CosineClamped
+)
+
+// cosineScore returns the cosine similarity scorer for mode. Unknown modes
+// score like CosineSigned.
+func cosineScore(mode CosineMode) func(v1, v2 []float64) (float64, error) {
+	adjust := func(sim float64) float64 { return sim }
+	switch mode {
+	case CosineAbsolute:
+		adjust = math.Abs
+	case CosineClamped:
+		adjust = func(sim float64) float64 { return math.Max(sim, 0) }
+	}
+	return func(v1, v2 []float64) (float64, error) {
+		sim, err := mathutils.CosineSimilarity(v1, v2)
+		return adjust(sim), err
+	}
+}
+
+// KNNCosMode returns a search func like KNNCos, scoring similarity
+// according to mode.
+func KNNCosMode(mode CosineMode) func([]float64, func() ([]float64, bool), int) []int {
+	score := cosineScore(mode)
+	return func(target []float64, vecs func() ([]float64, bool), k int) []int {
+		return search(target, vecs, k, score, true)
+	}
+}
+
+// KFNCosMode returns a search func like KFNCos, scoring similarity
+// according to mode.
+func KFNCosMode(mode CosineMode) func([]float64, func() ([]float64, bool), int) []int {
+	score := cosineScore(mode)
+	return func(target []float64, vecs func() ([]float64, bool), k int) []int {
+		return search(target, vecs, k, score, false)
+	}
+}
+
+// KNNEuc returns the indexes of the k vectors closest to target by Euclidean
+// distance, closest first.
+func KNNEuc(target []float64, vecs func() ([]float64, bool), k int) []int {
+	// Squared distances rank the same and skip the square root.
+	return search(target, vecs, k, mathutils.SquaredEuclideanDistance, false)
+}
+
+// KFNEuc returns the indexes of the k vectors furthest from target by
+// Euclidean distance, furthest first.
+func KFNEuc(target []float64, vecs func() ([]float64, bool), k int) []int {
+	return search(target, vecs, k, mathutils.SquaredEuclideanDistance, true)
+}
+
+// KNNManhattan returns the indexes of the k vectors closest to target by
+// Manhattan distance, closest first.
+func KNNManhattan(target []float64, vecs func() ([]float64, bool), k int) []int {
+	return search(target, vecs, k, mathutils.ManhattanDistance, false)
+}
+
+// KFNManhattan returns the indexes of the k vectors furthest from target by
+// Manhattan distance, furthest first.
+func KFNManhattan(target []float64, vecs func() ([]float64, bool), k int) []int {
+	return search(target, vecs, k, mathutils.ManhattanDistance, true)
+}
+
+// KNNWeightedEuc returns a search func ranking vectors by
+// mathutils.WeightedEuclideanDistance with the given per-dimension weights,
+// closest first. Vectors that don't match the weights in dimension are
+// skipped, as are all vectors if a weight is negative.
+func KNNWeightedEuc(weights []float64) func([]float64, func() ([]float64, bool), int) []int {
+	return KNNByMetric(weightedEuc(weights))
+}
+
+// KFNWeightedEuc is like KNNWeightedEuc, but ranks furthest first.
+func KFNWeightedEuc(weights []float64) func([]float64, func() ([]float64, bool), int) []int {
+	return KFNByMetric(weightedEuc(weights))
+}
+
+// weightedEuc binds weights to mathutils.WeightedEuclideanDistance.
+func weightedEuc(weights []float64) mathutils.Metric {
+	return func(v1, v2 []float64) (float64, error) {
+		return mathutils.WeightedEuclideanDistance(v1, v2, weights)
+	}
+}
+
+// KNNByMetric returns a search func ranking vectors by metric, where a
+// smaller distance is better, closest first. It ties a search to the same
+// notion of distance used elsewhere, e.g. a Centroid's Metric.
+func KNNByMetric(metric mathutils.Metric) func([]float64, func() ([]float64, bool), int) []int {
+	return func(target []float64, vecs func() ([]float64, bool), k int) []int {
+		return search(target, vecs, k, metric, false)
+	}
+}
+
+// KFNByMetric is like KNNByMetric, but ranks furthest first.
+func KFNByMetric(metric mathutils.Metric) func([]float64, func() ([]float64, bool), int) []int {
+	return func(target []float64, vecs func() ([]float64, bool), k int) []int {
+		return search(target, vecs, k, metric, true)
+	}
+}
+
+// search is the shared core of the search funcs. It scores every vector
+// from vecs against target and keeps the k best, where best means highest
+// score if higher is true and lowest otherwise. Equal scores keep generation
+// order, so results are deterministic.
+func search(
+	target []float64,
+	vecs func() ([]float64, bool),
+	k int,
+	score func(v1, v2 []float64) (float64, error),
+	higher bool,
+) []int {
+	return searchInto(target, vecs, k, score, higher, []int{})
+}
+
+// scoreBufLen is the k up to which searchInto keeps scores on the stack.
+const scoreBufLen = 64
+
+// searchInto is search writing the indexes into out; see KNNCosInto.
+func searchInto(
+	target []float64,
+	vecs func() ([]float64, bool),
+	k int,
+	score func(v1, v2 []float64) (float64, error),
+	higher bool,
+	out []int,
+) []int {
+	indexes := out[:0]
+	if k <= 0 || target == nil || vecs == nil {
+		return indexes
+	}
+
+	better := func(a, b float64) bool {
+		if higher {
+			return a > b
+		}
+		return a < b
+	}
+
+	var scoreBuf [scoreBufLen]float64
+	scores := scoreBuf[:0]
+	for i := 0; ; i++ {
+		v, ok := vecs()
+		if !ok {
+			break
+		}
+		s, err := score(target, v)
+		if err != nil {
+			continue
+		}
+		// Position after every kept score that is at least as good.
+		pos := len(scores)
+		for pos > 0 && better(s, scores[pos-1]) {
+			pos--
+		}
+		if pos >= k {
+			continue
+		}
+		if len(scores) < k {
+			indexes = append(indexes, 0)
+			scores = append(scores, 0)
+		}
+		copy(indexes[pos+1:], indexes[pos:])
+		copy(scores[pos+1:], scores[pos:])
+		indexes[pos], scores[pos] = i, s
+	}
+	return indexes
+}
@@ -0,0 +1,201 @@
+package searchutils
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+func TestSearchFuncs(t *testing.T) {
+	vecs := [][]float64{{1, 0}, {3, 0}, {0, 1}, {-2, 0}, {2, 2}}
+	target := []float64{1, 0}
+	tests := []struct {
+		name string
+		fn   func([]float64, func() ([]float64, bool), int) []int
+		want []int
+	}{
+		{"KNNCos", KNNCos, []int{0, 1, 4}},
+		{"KFNCos", KFNCos, []int{3, 2, 4}},
+		{"KNNEuc", KNNEuc, []int{0, 2, 1}},
+		{"KFNEuc", KFNEuc, []int{3, 4, 1}},
+		{"KNNManhattan", KNNManhattan, []int{0, 1, 2}},
+		{"KFNManhattan", KFNManhattan, []int{3, 4, 1}},
+	}
+	for _, test := range tests {
+		got := test.fn(target, mathutils.VecGenerator(vecs), 3)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSearchByMetric(t *testing.T) {
+	vecs := [][]float64{{1, 0}, {3, 0}, {0, 1}, {-2, 0}, {2, 2}}
+	target := []float64{1, 0}
+	tests := []struct {
+		name      string
+		got, want func([]float64, func() ([]float64, bool), int) []int
+	}{
+		{"KNN euclidean", KNNByMetric(mathutils.EuclideanDistance), KNNEuc},
+		{"KFN euclidean", KFNByMetric(mathutils.EuclideanDistance), KFNEuc},
+		{"KNN cosine", KNNByMetric(mathutils.CosineDistance), KNNCos},
+		{"KFN cosine", KFNByMetric(mathutils.CosineDistance), KFNCos},
+	}
+	for _, tt := range tests {
+		got := tt.got(target, mathutils.VecGenerator(vecs), 3)
+		want := tt.want(target, mathutils.VecGenerator(vecs), 3)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestSearchWeightedEuc(t *testing.T) {
+	vecs := [][]float64{{2, 0}, {0, 1.5}, {1, 1}}
+	target := []float64{0, 0}
+	// With the first dimension weighted down, {2, 0} moves closest.
+	weights := []float64{0.1, 1}
+	if got, want := KNNWeightedEuc(weights)(target, mathutils.VecGenerator(vecs), 3), []int{0, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("KNN: got %v, want %v", got, want)
+	}
+	if got, want := KFNWeightedEuc(weights)(target, mathutils.VecGenerator(vecs), 1), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("KFN: got %v, want %v", got, want)
+	}
+	if got := KNNWeightedEuc([]float64{1})(target, mathutils.VecGenerator(vecs), 3); len(got) != 0 {
+		t.Errorf("mismatched weights: got %v", got)
+	}
+}
+
+func TestSearchCosineModes(t *testing.T) {
+	// Anti-parallel, orthogonal, aligned, and nearly anti-parallel.
+	vecs := [][]float64{{-1, 0}, {0, 1}, {1, 1}, {-2, -0.1}}
+	target := []float64{1, 0}
+	tests := []struct {
+		mode     CosineMode
+		knn, kfn []int
+	}{
+		{CosineSigned, []int{2, 1, 3, 0}, []int{0, 3, 1, 2}},
+		{CosineAbsolute, []int{0, 3, 2, 1}, []int{1, 2, 3, 0}},
+		{CosineClamped, []int{2, 0, 1, 3}, []int{0, 1, 3, 2}},
+	}
+	for _, tt := range tests {
+		if got := KNNCosMode(tt.mode)(target, mathutils.VecGenerator(vecs), 4); !reflect.DeepEqual(got, tt.knn) {
+			t.Errorf("KNN mode %d: got %v, want %v", tt.mode, got, tt.knn)
+		}
+		if got := KFNCosMode(tt.mode)(target, mathutils.VecGenerator(vecs), 4); !reflect.DeepEqual(got, tt.kfn) {
+			t.Errorf("KFN mode %d: got %v, want %v", tt.mode, got, tt.kfn)
+		}
+	}
+	if got, want := KNNCosMode(CosineSigned)(target, mathutils.VecGenerator(vecs), 4), KNNCos(target, mathutils.VecGenerator(vecs), 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("signed: got %v, KNNCos gave %v", got, want)
+	}
+}
+
+func TestSearchDuplicates(t *testing.T) {
+	vecs := [][]float64{{1, 1}, {1, 1}, {1, 1}, {1, 1}}
+	funcs := map[string]func([]float64, func() ([]float64, bool), int) []int{
+		"KNNCos":       KNNCos,
+		"KFNCos":       KFNCos,
+		"KNNEuc":       KNNEuc,
+		"KFNEuc":       KFNEuc,
+		"KNNManhattan": KNNManhattan,
+		"KFNManhattan": KFNManhattan,
+		"KNNByMetric":  KNNByMetric(mathutils.ChebyshevDistance),
+		"KFNByMetric":  KFNByMetric(mathutils.ChebyshevDistance),
+	}
+	for name, fn := range funcs {
+		if got, want := fn([]float64{2, 1}, mathutils.VecGenerator(vecs), 10), []int{0, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s, k > count: got %v, want %v", name, got, want)
+		}
+		if got, want := fn([]float64{2, 1}, mathutils.VecGenerator(vecs), 3), []int{0, 1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s, k < count: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSearchSkipsIncomparable(t *testing.T) {
+	vecs := [][]float64{nil, {1, 2, 3}, {5, 5}, {1, 1}}
+	got := KNNEuc([]float64{0, 0}, mathutils.VecGenerator(vecs), 10)
+	if want := []int{3, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSearchEdgeCases(t *testing.T) {
+	vecs := [][]float64{{1}, {1}, {1}}
+	if got := KNNEuc([]float64{0}, mathutils.VecGenerator(vecs), 0); len(got) != 0 {
+		t.Errorf("k=0: got %v", got)
+	}
+	// Ties keep generation order.
+	got := KNNEuc([]float64{0}, mathutils.VecGenerator(vecs), 2)
+	if want := []int{0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ties: got %v, want %v", got, want)
+	}
+}
+
+func TestKNNCosInto(t *testing.T) {
+	vecs := [][]float64{{1, 0}, {3, 1}, {0, 1}, {-2, 0}, {2, 2}, {1, 1}}
+	target := []float64{1, 0.5}
+	out := make([]int, 0, 2)
+	for k := 0; k <= len(vecs)+1; k++ {
+		want := KNNCos(target, mathutils.VecGenerator(vecs), k)
+		got := KNNCosInto(target, mathutils.VecGenerator(vecs), k, out)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("k=%d: got %v, want %v", k, got, want)
+		}
+		if k <= cap(out) && k > 0 && &got[0] != &out[:1][0] {
+			t.Errorf("k=%d: result does not reuse out", k)
+		}
+	}
+}
+
+// reusableGenerator returns a generator over vecs and a func rewinding it,
+// so benchmarks don't measure generator allocations.
+func reusableGenerator(vecs [][]float64) (func() ([]float64, bool), func()) {
+	i := 0
+	gen := func() ([]float64, bool) {
+		if i == len(vecs) {
+			return nil, false
+		}
+		i++
+		return vecs[i-1], true
+	}
+	return gen, func() { i = 0 }
+}
+
+func benchmarkVecs(n, dim int) [][]float64 {
+	rng := rand.New(rand.NewSource(1))
+	vecs := make([][]float64, n)
+	for i := range vecs {
+		vecs[i] = make([]float64, dim)
+		for j := range vecs[i] {
+			vecs[i][j] = rng.NormFloat64()
+		}
+	}
+	return vecs
+}
+
+func BenchmarkKNNCos(b *testing.B) {
+	vecs := benchmarkVecs(100, 16)
+	gen, rewind := reusableGenerator(vecs)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rewind()
+		KNNCos(vecs[0], gen, 10)
+	}
+}
+
+func BenchmarkKNNCosInto(b *testing.B) {
+	vecs := benchmarkVecs(100, 16)
+	gen, rewind := reusableGenerator(vecs)
+	out := make([]int, 0, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rewind()
+		out = KNNCosInto(vecs[0], gen, 10, out)
+	}
+}
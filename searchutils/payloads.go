@@ -0,0 +1,42 @@
+package searchutils
+
+import (
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// KNNPayloads returns the k payloads whose vectors are closest to target
+// under metric, closest first, for callers holding plain payload slices
+// rather than centroids. Expired payloads, nil payloads and payloads that
+// can't be compared with target are skipped; k larger than the number of
+// candidates returns them all. A nil metric means
+// mathutils.EuclideanDistance.
+func KNNPayloads(
+	target []float64,
+	payloads []common.PayloadContainer,
+	k int,
+	metric mathutils.Metric,
+) []common.PayloadContainer {
+	if metric == nil {
+		metric = mathutils.EuclideanDistance
+	}
+	i := 0
+	vecs := func() ([]float64, bool) {
+		if i == len(payloads) {
+			return nil, false
+		}
+		p := payloads[i]
+		i++
+		if p == nil || p.Expired() {
+			return nil, true // Skipped by search, keeping indexes aligned.
+		}
+		return p.Vec(), true
+	}
+
+	indexes := search(target, vecs, k, metric, false)
+	result := make([]common.PayloadContainer, len(indexes))
+	for j, index := range indexes {
+		result[j] = payloads[index]
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package searchutils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crunchypi/net-means/common"
+	"github.com/crunchypi/net-means/mathutils"
+)
+
+// testPayload is a minimal common.PayloadContainer for tests.
+type testPayload struct {
+	vec     []float64
+	expired bool
+}
+
+func (p *testPayload) Vec() []float64 { return p.vec }
+func (p *testPayload) Expired() bool  { return p.expired }
+
+func TestKNNPayloads(t *testing.T) {
+	near := &testPayload{vec: []float64{1}}
+	mid := &testPayload{vec: []float64{3}}
+	far := &testPayload{vec: []float64{-6}}
+	payloads := []common.PayloadContainer{
+		far,
+		&testPayload{vec: []float64{0}, expired: true},
+		nil,
+		&testPayload{vec: []float64{0, 0}},
+		mid,
+		near,
+	}
+
+	tests := []struct {
+		k    int
+		want []common.PayloadContainer
+	}{
+		{0, []common.PayloadContainer{}},
+		{2, []common.PayloadContainer{near, mid}},
+		{10, []common.PayloadContainer{near, mid, far}},
+	}
+	for _, tt := range tests {
+		got := KNNPayloads([]float64{0}, payloads, tt.k, nil)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("k=%d: got %v, want %v", tt.k, got, tt.want)
+		}
+	}
+}
+
+func TestKNNPayloadsMetric(t *testing.T) {
+	aligned := &testPayload{vec: []float64{10, 0}}
+	nearby := &testPayload{vec: []float64{1, 1}}
+	payloads := []common.PayloadContainer{nearby, aligned}
+	cosine, _ := mathutils.MetricByName("cosine")
+
+	got := KNNPayloads([]float64{1, 0}, payloads, 1, cosine)
+	if want := []common.PayloadContainer{aligned}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}